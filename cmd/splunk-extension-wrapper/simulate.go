@@ -0,0 +1,187 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/splunk/lambda-extension/internal/config"
+	"github.com/splunk/lambda-extension/internal/emulator"
+	"github.com/splunk/lambda-extension/internal/exporter"
+	"github.com/splunk/lambda-extension/internal/metrics"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const simulateCommand = "simulate"
+
+const runtimeApiEnv = "AWS_LAMBDA_RUNTIME_API"
+const telemetryListenerEnv = "SPLUNK_TELEMETRY_LISTENER"
+const simulatedTelemetryListener = "127.0.0.1:0"
+
+const defaultScenarioFunctionName = "simulated-function"
+const defaultScenarioFunctionVersion = "$LATEST"
+const defaultScenarioArnPrefix = "arn:aws:lambda:us-east-1:123456789012:function:"
+
+// scenario is read from a YAML (or JSON) file, durations are strings like "150ms" or "5s"
+type scenario struct {
+	FunctionName    string            `yaml:"functionName"`
+	FunctionVersion string            `yaml:"functionVersion"`
+	FunctionArn     string            `yaml:"functionArn"`
+	Environment     map[string]string `yaml:"environment"`
+	Steps           []scenarioStep    `yaml:"steps"`
+}
+
+type scenarioStep struct {
+	Invoke   *scenarioInvocation `yaml:"invoke"`
+	Freeze   time.Duration       `yaml:"freeze"`
+	Shutdown string              `yaml:"shutdown"`
+}
+
+type scenarioInvocation struct {
+	FunctionArn string        `yaml:"functionArn"`
+	Qualifier   string        `yaml:"qualifier"`
+	Count       int           `yaml:"count"`
+	Interval    time.Duration `yaml:"interval"`
+	Duration    time.Duration `yaml:"duration"`
+	Timeout     time.Duration `yaml:"timeout"`
+	Status      string        `yaml:"status"`
+}
+
+// simulate runs the extension against the scripted invocations of a scenario,
+// the datapoints are written to out instead of being sent
+func simulate(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: " + extensionName() + " " + simulateCommand + " <scenario.yaml>")
+	}
+
+	s, err := loadScenario(args[0])
+	if err != nil {
+		return err
+	}
+
+	api, err := emulator.NewRuntimeApi(s.FunctionName, s.FunctionVersion, s.emulatorSteps())
+	if err != nil {
+		return fmt.Errorf("can't start the runtime API emulator: %v", err)
+	}
+	defer api.Close()
+
+	for k, v := range s.Environment {
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	if err := os.Setenv(runtimeApiEnv, api.Host()); err != nil {
+		return err
+	}
+	if _, found := os.LookupEnv(telemetryListenerEnv); !found {
+		if err := os.Setenv(telemetryListenerEnv, simulatedTelemetryListener); err != nil {
+			return err
+		}
+	}
+
+	configuration := config.New()
+
+	// the destinations aren't used by the simulation, so the configuration errors are only reported
+	logValidationErrors(configuration.Validate())
+
+	initLogging(&configuration)
+
+	m := metrics.NewWithSink(&configuration, exporter.NewPrinter(out))
+
+	sc := registerApiAndStartMainLoop(true, m, &configuration, nil)
+	m.Shutdown(sc)
+
+	if sc.IsError() {
+		return fmt.Errorf("simulation failed: %v (%v)", sc.Reason(), sc.Message())
+	}
+
+	return nil
+}
+
+func loadScenario(path string) (*scenario, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the scenario: %v", err)
+	}
+
+	// JSON is a subset of YAML, so both formats are accepted
+	s := &scenario{}
+	if err := yaml.Unmarshal(content, s); err != nil {
+		return nil, fmt.Errorf("can't parse the scenario %v: %v", path, err)
+	}
+
+	if s.FunctionName == "" {
+		s.FunctionName = defaultScenarioFunctionName
+	}
+	if s.FunctionVersion == "" {
+		s.FunctionVersion = defaultScenarioFunctionVersion
+	}
+	if s.FunctionArn == "" {
+		s.FunctionArn = defaultScenarioArnPrefix + s.FunctionName
+	}
+
+	return s, nil
+}
+
+func (s *scenario) emulatorSteps() []emulator.Step {
+	var steps []emulator.Step
+
+	for _, step := range s.Steps {
+		switch {
+		case step.Invoke != nil:
+			steps = append(steps, s.invocations(step.Invoke)...)
+		case step.Freeze > 0:
+			steps = append(steps, emulator.Step{Freeze: step.Freeze})
+		case step.Shutdown != "":
+			steps = append(steps, emulator.Step{Shutdown: step.Shutdown})
+		}
+	}
+
+	return steps
+}
+
+func (s *scenario) invocations(invocation *scenarioInvocation) []emulator.Step {
+	functionArn := invocation.FunctionArn
+	if functionArn == "" {
+		functionArn = s.FunctionArn
+	}
+	if invocation.Qualifier != "" {
+		functionArn += ":" + invocation.Qualifier
+	}
+
+	count := invocation.Count
+	if count < 1 {
+		count = 1
+	}
+
+	var steps []emulator.Step
+	for i := 0; i < count; i++ {
+		if i > 0 && invocation.Interval > 0 {
+			steps = append(steps, emulator.Step{Freeze: invocation.Interval})
+		}
+		steps = append(steps, emulator.Step{Invoke: &emulator.Invocation{
+			FunctionArn: functionArn,
+			Duration:    invocation.Duration,
+			Timeout:     invocation.Timeout,
+			Status:      invocation.Status,
+		}})
+	}
+
+	return steps
+}
@@ -0,0 +1,89 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testScenario = `
+functionName: helloworld
+functionVersion: "3"
+environment:
+  SPLUNK_ACCESS_TOKEN: test-token-0123456789
+steps:
+  - invoke: {qualifier: live, count: 2, duration: 15ms}
+  - freeze: 10ms
+  - invoke: {qualifier: test, status: error}
+  - shutdown: timeout
+`
+
+func TestSimulate(t *testing.T) {
+	// restores the variables set by the simulation
+	t.Setenv(runtimeApiEnv, "")
+	t.Setenv(telemetryListenerEnv, simulatedTelemetryListener)
+	t.Setenv("SPLUNK_ACCESS_TOKEN", "")
+
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := ioutil.WriteFile(path, []byte(testScenario), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := simulate([]string{path}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	printed := out.String()
+	expected := []string{
+		"lambda.function.invocation counter 1 {",
+		"aws_function_qualifier=live",
+		"lambda.function.errors counter 1 {",
+		"aws_function_shutdown_cause=timeout",
+		"aws_function_name=helloworld",
+		"aws_function_version=3",
+	}
+	for _, e := range expected {
+		if !strings.Contains(printed, e) {
+			t.Errorf("Expected `%v` in the output, got `%v`", e, printed)
+		}
+	}
+}
+
+func TestScenarioSteps(t *testing.T) {
+	s := &scenario{
+		FunctionArn: defaultScenarioArnPrefix + "helloworld",
+		Steps: []scenarioStep{
+			{Invoke: &scenarioInvocation{Qualifier: "live", Count: 3, Interval: 5}},
+			{Shutdown: "spindown"},
+		},
+	}
+
+	steps := s.emulatorSteps()
+
+	if len(steps) != 6 {
+		t.Fatalf("Expected `%v`, got `%v`", 6, len(steps))
+	}
+	if arn := steps[0].Invoke.FunctionArn; arn != defaultScenarioArnPrefix+"helloworld:live" {
+		t.Errorf("Expected `%v`, got `%v`", defaultScenarioArnPrefix+"helloworld:live", arn)
+	}
+	if steps[1].Freeze != 5 || steps[5].Shutdown != "spindown" {
+		t.Errorf("Unexpected steps: %v", steps)
+	}
+}
@@ -19,8 +19,11 @@ import (
 	"github.com/splunk/lambda-extension/internal/extensionapi"
 	"github.com/splunk/lambda-extension/internal/metrics"
 	"github.com/splunk/lambda-extension/internal/ossignal"
+	"github.com/splunk/lambda-extension/internal/receiver"
 	"github.com/splunk/lambda-extension/internal/shutdown"
+	"github.com/splunk/lambda-extension/internal/telemetry"
 	"bufio"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -28,6 +31,7 @@ import (
 	"path"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // the correct value is set by the go linker (it's done during build using "ldflags")
@@ -36,16 +40,33 @@ var gitVersion string
 const enabledKey = "SPLUNK_EXTENSION_WRAPPER_ENABLED"
 const extensionNameKey = "SPLUNK_EXTENSION_WRAPPER_NAME"
 
+// the function error type reported when the configuration is invalid
+const configErrorType = "Extension.InvalidConfiguration"
+
+const telemetryQuietPeriod = 100 * time.Millisecond
+const telemetryMaxWait = 500 * time.Millisecond
+
 func enabled() bool {
 	s := strings.ToLower(os.Getenv(enabledKey))
 	return s != "0" && s != "false"
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == simulateCommand {
+		if err := simulate(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	enabled := enabled()
 
 	configuration := config.New()
 
+	// reported before the logging is set up, so the misconfiguration is visible even without VERBOSE
+	configErr := configError(enabled, &configuration)
+
 	initLogging(&configuration)
 
 	ossignal.Watch()
@@ -55,10 +76,10 @@ func main() {
 	// but 3 or 4 nil checks will do for now, since they're all in one file
 	var m *metrics.MetricEmitter = nil
 	if enabled {
-		m = metrics.New()
+		m = metrics.New(&configuration)
 	}
 
-	shutdownCondition := registerApiAndStartMainLoop(enabled, m, &configuration)
+	shutdownCondition := registerApiAndStartMainLoop(enabled, m, &configuration, configErr)
 
 	if shutdownCondition.IsError() {
 		log.SetOutput(os.Stderr)
@@ -67,12 +88,14 @@ func main() {
 	log.Println("shutdown reason:", shutdownCondition.Reason())
 	log.Println("shutdown message:", shutdownCondition.Message())
 
-	if m != nil {
+	// nothing can be sent with an invalid configuration
+	if m != nil && configErr == nil {
 		m.Shutdown(shutdownCondition)
 	}
 }
 
-func registerApiAndStartMainLoop(enabled bool, m *metrics.MetricEmitter, configuration *config.Configuration) (sc shutdown.Condition) {
+// registerApiAndStartMainLoop reports configErr (the result of configuration.Validate) as an init error, if not nil
+func registerApiAndStartMainLoop(enabled bool, m *metrics.MetricEmitter, configuration *config.Configuration, configErr error) (sc shutdown.Condition) {
 	var api *extensionapi.RegisteredApi
 
 	defer func() {
@@ -87,6 +110,11 @@ func registerApiAndStartMainLoop(enabled bool, m *metrics.MetricEmitter, configu
 
 	api, sc = extensionapi.Register(enabled, extensionName(), configuration)
 
+	if sc == nil && configErr != nil {
+		api.InitError(configErrorType)
+		return shutdown.Config(configErr.Error())
+	}
+
 	if sc == nil {
 		sc = mainLoop(api, m, configuration)
 	}
@@ -101,6 +129,16 @@ func registerApiAndStartMainLoop(enabled bool, m *metrics.MetricEmitter, configu
 func mainLoop(api *extensionapi.RegisteredApi, m *metrics.MetricEmitter, configuration *config.Configuration) (sc shutdown.Condition) {
 	if m != nil {
 		m.SetFunction(api.FunctionName, api.FunctionVersion)
+
+		startReceivers(m, configuration)
+
+		if configuration.TelemetryEnabled {
+			if listener := subscribeTelemetry(api, m, configuration); listener != nil {
+				// the reports of the last invocations are delivered after the environment is thawed for SHUTDOWN
+				defer listener.WaitQuiet(telemetryQuietPeriod, telemetryMaxWait)
+				m.TelemetrySubscribed()
+			}
+		}
 	}
 
 	var event *extensionapi.Event
@@ -108,7 +146,8 @@ func mainLoop(api *extensionapi.RegisteredApi, m *metrics.MetricEmitter, configu
 
 	for sc == nil {
 		if m != nil {
-			sc = m.Invoked(event.InvokedFunctionArn, configuration.SplunkFailFast)
+			m.Invoked(event)
+			sc = m.Drain(configuration.SplunkFailFast)
 		}
 		if sc == nil {
 			event, sc = api.NextEvent()
@@ -118,6 +157,65 @@ func mainLoop(api *extensionapi.RegisteredApi, m *metrics.MetricEmitter, configu
 	return
 }
 
+func subscribeTelemetry(api *extensionapi.RegisteredApi, m *metrics.MetricEmitter, configuration *config.Configuration) *telemetry.Listener {
+	listener, err := telemetry.Listen(configuration.TelemetryListener, m)
+	if err != nil {
+		log.Printf("can't start the telemetry listener: %v\n", err)
+		return nil
+	}
+
+	types := []string{extensionapi.TelemetryPlatform}
+	if configuration.EmfEnabled {
+		types = append(types, extensionapi.TelemetryFunction)
+	}
+
+	if err := api.SubscribeTelemetry(listener.URI(), types); err != nil {
+		log.Printf("telemetry metrics are disabled: %v\n", err)
+		listener.Close()
+		return nil
+	}
+
+	return listener
+}
+
+func startReceivers(m *metrics.MetricEmitter, configuration *config.Configuration) {
+	if configuration.CustomMetricsEnabled {
+		if err := receiver.ListenDatapoints(configuration.CustomMetricsListener, m); err != nil {
+			log.Printf("can't start the custom metrics receiver: %v\n", err)
+		}
+	}
+
+	if configuration.StatsdEnabled {
+		aggregator := receiver.NewStatsdAggregator()
+		if err := receiver.ListenStatsd(configuration.StatsdListener, aggregator); err != nil {
+			log.Printf("can't start the statsd receiver: %v\n", err)
+		} else {
+			m.AddCollector(aggregator)
+		}
+	}
+}
+
+// configError validates the configuration, the errors are always logged,
+// but they only fail the init when SPLUNK_STRICT_CONFIG is set (and the extension is enabled)
+func configError(enabled bool, configuration *config.Configuration) error {
+	err := configuration.Validate()
+	logValidationErrors(err)
+
+	if !enabled || !configuration.StrictConfig {
+		return nil
+	}
+	return err
+}
+
+func logValidationErrors(err error) {
+	var errs config.ValidationErrors
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			log.Printf("[ERROR] %v\n", e)
+		}
+	}
+}
+
 func initLogging(configuration *config.Configuration) {
 	en := extensionName()
 	log.SetPrefix("[" + en + "] ")
@@ -0,0 +1,178 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/splunk/lambda-extension/internal/config"
+	"github.com/splunk/lambda-extension/internal/emulator"
+	"github.com/splunk/lambda-extension/internal/metrics"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testArn = "arn:aws:lambda:us-east-1:123456789012:function:helloworld"
+const testToken = "test-token-0123456789"
+
+func startEmulators(t *testing.T, steps []emulator.Step) (*emulator.RuntimeApi, *emulator.Ingest) {
+	api, err := emulator.NewRuntimeApi("helloworld", "7", steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(api.Close)
+
+	ingest, err := emulator.NewIngest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ingest.Close)
+
+	t.Setenv("AWS_LAMBDA_RUNTIME_API", api.Host())
+	t.Setenv("AWS_EXECUTION_ENV", "AWS_Lambda_nodejs18.x")
+	t.Setenv("SPLUNK_METRICS_ENDPOINT", ingest.Url())
+	t.Setenv("SPLUNK_ACCESS_TOKEN", testToken)
+	t.Setenv("SPLUNK_TELEMETRY_LISTENER", "127.0.0.1:0")
+
+	return api, ingest
+}
+
+func TestMainLoop(t *testing.T) {
+	api, ingest := startEmulators(t, []emulator.Step{
+		{Invoke: &emulator.Invocation{FunctionArn: testArn + ":live", Duration: 20 * time.Millisecond}},
+		{Invoke: &emulator.Invocation{FunctionArn: testArn + ":live", Duration: 30 * time.Millisecond, Status: "error"}},
+		{Freeze: 10 * time.Millisecond},
+		{Invoke: &emulator.Invocation{FunctionArn: testArn + ":test", Status: "timeout"}},
+		{Shutdown: "spindown"},
+	})
+
+	configuration := config.New()
+	m := metrics.New(&configuration)
+
+	sc := registerApiAndStartMainLoop(true, m, &configuration, configError(true, &configuration))
+	m.Shutdown(sc)
+
+	if sc.IsError() || sc.Reason() != "spindown" {
+		t.Fatalf("Unexpected shutdown condition: %v %v", sc.Reason(), sc.Message())
+	}
+
+	if events := api.RegisteredEvents(); !reflect.DeepEqual(events, []string{"INVOKE", "SHUTDOWN"}) {
+		t.Errorf("Unexpected registered events: %v", events)
+	}
+	if subscription := api.Subscription(); subscription == nil || !reflect.DeepEqual(subscription.Types, []string{"platform"}) {
+		t.Errorf("Unexpected telemetry subscription: %v", subscription)
+	}
+
+	live := map[string]string{"aws_function_qualifier": "live", "aws_function_name": "helloworld", "aws_function_version": "7"}
+	test := map[string]string{"aws_function_qualifier": "test"}
+
+	expected := []struct {
+		metric string
+		dims   map[string]string
+		value  float64
+	}{
+		{"lambda.function.invocation", live, 2},
+		{"lambda.function.invocation", test, 1},
+		{"lambda.function.cold_start", live, 1},
+		{"lambda.function.errors", live, 1},
+		{"lambda.function.timeouts", test, 1},
+		{"lambda.function.duration.sum", live, 50},
+		{"lambda.function.duration.count", live, 2},
+		{"lambda.function.initialization", nil, 1},
+		{"lambda.function.environment.invocations", nil, 3},
+		{"lambda.function.init", map[string]string{"init_type": "on-demand", "status": "success"}, 1},
+		{"lambda.function.shutdown", map[string]string{"aws_function_shutdown_cause": "spindown"}, 1},
+	}
+	for _, e := range expected {
+		if actual := ingest.Sum(e.metric, e.dims); actual != e.value {
+			t.Errorf("Expected %v of %v %v, got %v", e.value, e.metric, e.dims, actual)
+		}
+	}
+
+	for _, token := range ingest.Tokens() {
+		if token != testToken {
+			t.Errorf("Unexpected token: %v", token)
+		}
+	}
+
+	if errors := api.Errors(); len(errors) != 0 {
+		t.Errorf("Unexpected errors reported: %v", errors)
+	}
+}
+
+func TestMainLoopWhenDisabled(t *testing.T) {
+	api, ingest := startEmulators(t, nil)
+
+	configuration := config.New()
+
+	sc := registerApiAndStartMainLoop(false, nil, &configuration, nil)
+
+	if sc.IsError() {
+		t.Fatalf("Unexpected shutdown condition: %v %v", sc.Reason(), sc.Message())
+	}
+	if events := api.RegisteredEvents(); !reflect.DeepEqual(events, []string{"SHUTDOWN"}) {
+		t.Errorf("Unexpected registered events: %v", events)
+	}
+	if dps := ingest.Datapoints(); len(dps) != 0 {
+		t.Errorf("Expected no datapoints, got %v", dps)
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	api, ingest := startEmulators(t, []emulator.Step{
+		{Invoke: &emulator.Invocation{FunctionArn: testArn}},
+	})
+	t.Setenv("SPLUNK_REALM", "us0")
+	t.Setenv("SPLUNK_ACCESS_TOKEN", "")
+	t.Setenv("SPLUNK_STRICT_CONFIG", "true")
+
+	configuration := config.New()
+
+	sc := registerApiAndStartMainLoop(true, metrics.New(&configuration), &configuration, configError(true, &configuration))
+
+	if !sc.IsError() || sc.Reason() != "config" {
+		t.Errorf("Unexpected shutdown condition: %v %v", sc.Reason(), sc.Message())
+	}
+	if errors := api.Errors(); !reflect.DeepEqual(errors, []string{configErrorType}) {
+		t.Errorf("Expected `%v`, got `%v`", []string{configErrorType}, errors)
+	}
+	if dps := ingest.Datapoints(); len(dps) != 0 {
+		t.Errorf("Expected no datapoints, got %v", dps)
+	}
+}
+
+func TestInvalidConfigurationNotStrict(t *testing.T) {
+	api, ingest := startEmulators(t, []emulator.Step{
+		{Invoke: &emulator.Invocation{FunctionArn: testArn}},
+		{Shutdown: "spindown"},
+	})
+	// conflicts with the custom endpoint of the emulator, which is used anyway
+	t.Setenv("SPLUNK_REALM", "us0")
+
+	configuration := config.New()
+	m := metrics.New(&configuration)
+
+	sc := registerApiAndStartMainLoop(true, m, &configuration, configError(true, &configuration))
+	m.Shutdown(sc)
+
+	if sc.IsError() || sc.Reason() != "spindown" {
+		t.Errorf("Unexpected shutdown condition: %v %v", sc.Reason(), sc.Message())
+	}
+	if errors := api.Errors(); len(errors) != 0 {
+		t.Errorf("Unexpected errors reported: %v", errors)
+	}
+	if actual := ingest.Sum("lambda.function.invocation", nil); actual != 1 {
+		t.Errorf("Expected %v invocations, got %v", 1, actual)
+	}
+}
@@ -16,6 +16,7 @@ package config
 
 import (
 	"fmt"
+	"github.com/splunk/lambda-extension/internal/secrets"
 	"log"
 	"os"
 	"strconv"
@@ -33,8 +34,31 @@ const defaultVerbose = false
 const defaultHttpTracing = false
 const defaultFailFast = false
 const defaultInsecureSkipHTTPSVerify = false
+const defaultTelemetryEnabled = true
+const defaultTelemetryListener = "sandbox.localdomain:4243"
+const defaultCustomMetricsEnabled = false
+const defaultCustomMetricsListener = "localhost:9943"
+const defaultStatsdEnabled = false
+const defaultStatsdListener = "localhost:8125"
+const defaultEmfEnabled = false
+const defaultMetricsExporter = ExporterSignalFx
+const defaultOtlpEndpoint = "http://localhost:4318"
+const defaultSpoolEnabled = false
+const defaultSpoolDir = "/tmp/splunk-extension-spool"
+const defaultSpoolMaxBytes = 10 * 1024 * 1024
+const defaultSpoolMaxAge = time.Hour
+const defaultColdStartDimension = false
+const defaultPercentiles = "50,90,99"
+const defaultDeadlineMarginMs = 200
+const defaultTracesEnabled = false
+const defaultTracesExporter = TracesExporterSAPM
+const defaultStrictConfig = false
 
 const ingestUrlFormat = "https://ingest.%s.signalfx.com"
+const otlpMetricsPath = "/v1/metrics"
+const signalFxDatapointPath = "/v2/datapoint"
+const otlpTracesPath = "/v1/traces"
+const sapmTracePath = "/v2/trace/sapm"
 
 const minTokenLength = 10 // SFx Access Tokens are 22 chars long in 2019 but accept 10 or more chars just in case
 
@@ -49,6 +73,29 @@ const verboseEnv = "VERBOSE"
 const httpTracingEnv = "HTTP_TRACING"
 const failFastEnv = "SPLUNK_EXPERIMENTAL_FAIL_FAST"
 const insecureSkipHTTPSVerifyEnv = "INSECURE_SKIP_HTTPS_VERIFY"
+const telemetryEnabledEnv = "SPLUNK_TELEMETRY_ENABLED"
+const telemetryListenerEnv = "SPLUNK_TELEMETRY_LISTENER"
+const customMetricsEnabledEnv = "SPLUNK_CUSTOM_METRICS_ENABLED"
+const customMetricsListenerEnv = "SPLUNK_CUSTOM_METRICS_LISTENER"
+const statsdEnabledEnv = "SPLUNK_STATSD_ENABLED"
+const statsdListenerEnv = "SPLUNK_STATSD_LISTENER"
+const emfEnabledEnv = "SPLUNK_EMF_ENABLED"
+const metricsExporterEnv = "SPLUNK_METRICS_EXPORTER"
+const otlpEndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+const otlpMetricsEndpointEnv = "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"
+const prometheusRemoteWriteUrlEnv = "SPLUNK_PROMETHEUS_REMOTE_WRITE_URL"
+const spoolEnabledEnv = "SPLUNK_SPOOL_ENABLED"
+const spoolDirEnv = "SPLUNK_SPOOL_DIR"
+const spoolMaxBytesEnv = "SPLUNK_SPOOL_MAX_BYTES"
+const spoolMaxAgeEnv = "SPLUNK_SPOOL_MAX_AGE"
+const coldStartDimensionEnv = "SPLUNK_COLD_START_DIMENSION"
+const percentilesEnv = "SPLUNK_PERCENTILES"
+const deadlineMarginEnv = "SPLUNK_DEADLINE_MARGIN_MS"
+const tracesEnabledEnv = "SPLUNK_TRACES_ENABLED"
+const tracesExporterEnv = "SPLUNK_TRACES_EXPORTER"
+const tracesEndpointEnv = "SPLUNK_TRACES_ENDPOINT"
+const otlpTracesEndpointEnv = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+const strictConfigEnv = "SPLUNK_STRICT_CONFIG"
 
 type Configuration struct {
 	SplunkRealm             string
@@ -61,35 +108,95 @@ type Configuration struct {
 	HttpTracing             bool
 	SplunkFailFast          bool
 	InsecureSkipHTTPSVerify bool
+	TelemetryEnabled        bool
+	TelemetryListener       string
+	CustomMetricsEnabled    bool
+	CustomMetricsListener   string
+	StatsdEnabled           bool
+	StatsdListener          string
+	EmfEnabled              bool
+	MetricsExporter         string
+	OtlpMetricsUrl          string
+	RemoteWriteUrl          string
+	Destinations            []Destination
+	SpoolEnabled            bool
+	SpoolDir                string
+	SpoolMaxBytes           int64
+	SpoolMaxAge             time.Duration
+	ColdStartDimension      bool
+	Percentiles             []float64
+	DeadlineMargin          time.Duration
+	ConfigFile              string
+	Dimensions              map[string]string
+	DimensionsInclude       []string
+	DimensionsExclude       []string
+	DimensionsRename        map[string]string
+	TracesEnabled           bool
+	TracesExporter          string
+	TracesUrl               string
+	StrictConfig            bool
+
+	parseErrors []ValidationError
 }
 
 func New() Configuration {
+	l := &loader{}
+	configFile := l.loadFile()
+
 	configuration := Configuration{
-		SplunkRealm:             strOrDefault(realmEnv, defaultRealm),
-		SplunkMetricsUrl:        strOrDefault(ingestURLEnv, strOrDefault(ingestURLEnvDeprecated, defaultIngestURL)),
-		SplunkToken:             strOrDefault(tokenEnv, defaultToken),
-		FastIngest:              boolOrDefault(fastIngestEnv, defaultFastIngest),
-		ReportingDelay:          durationOrDefault(reportingDelayEnv, defaultReportingDuration),
-		ReportingTimeout:        durationOrDefault(reportingTimeoutEnv, defaultReportingTimeout),
-		Verbose:                 boolOrDefault(verboseEnv, defaultVerbose),
-		HttpTracing:             boolOrDefault(httpTracingEnv, defaultHttpTracing),
-		SplunkFailFast:          boolOrDefault(failFastEnv, defaultFailFast),
-		InsecureSkipHTTPSVerify: boolOrDefault(insecureSkipHTTPSVerifyEnv, defaultInsecureSkipHTTPSVerify),
+		SplunkRealm:             l.strOrDefault(realmEnv, defaultRealm),
+		SplunkMetricsUrl:        l.chainOrDefault(defaultIngestURL, fallbackKey{key: ingestURLEnv}, fallbackKey{key: ingestURLEnvDeprecated}),
+		SplunkToken:             l.strOrDefault(tokenEnv, defaultToken),
+		FastIngest:              l.boolOrDefault(fastIngestEnv, defaultFastIngest),
+		ReportingDelay:          l.durationOrDefault(reportingDelayEnv, defaultReportingDuration),
+		ReportingTimeout:        l.durationOrDefault(reportingTimeoutEnv, defaultReportingTimeout),
+		Verbose:                 l.boolOrDefault(verboseEnv, defaultVerbose),
+		HttpTracing:             l.boolOrDefault(httpTracingEnv, defaultHttpTracing),
+		SplunkFailFast:          l.boolOrDefault(failFastEnv, defaultFailFast),
+		InsecureSkipHTTPSVerify: l.boolOrDefault(insecureSkipHTTPSVerifyEnv, defaultInsecureSkipHTTPSVerify),
+		TelemetryEnabled:        l.boolOrDefault(telemetryEnabledEnv, defaultTelemetryEnabled),
+		TelemetryListener:       l.strOrDefault(telemetryListenerEnv, defaultTelemetryListener),
+		CustomMetricsEnabled:    l.boolOrDefault(customMetricsEnabledEnv, defaultCustomMetricsEnabled),
+		CustomMetricsListener:   l.strOrDefault(customMetricsListenerEnv, defaultCustomMetricsListener),
+		StatsdEnabled:           l.boolOrDefault(statsdEnabledEnv, defaultStatsdEnabled),
+		StatsdListener:          l.strOrDefault(statsdListenerEnv, defaultStatsdListener),
+		EmfEnabled:              l.boolOrDefault(emfEnabledEnv, defaultEmfEnabled),
+		MetricsExporter:         strings.ToLower(l.strOrDefault(metricsExporterEnv, defaultMetricsExporter)),
+		OtlpMetricsUrl:          l.otlpUrl(otlpMetricsPath, otlpMetricsEndpointEnv),
+		RemoteWriteUrl:          l.strOrDefault(prometheusRemoteWriteUrlEnv, ""),
+		SpoolEnabled:            l.boolOrDefault(spoolEnabledEnv, defaultSpoolEnabled),
+		SpoolDir:                l.strOrDefault(spoolDirEnv, defaultSpoolDir),
+		SpoolMaxBytes:           l.intOrDefault(spoolMaxBytesEnv, defaultSpoolMaxBytes),
+		SpoolMaxAge:             l.durationOrDefault(spoolMaxAgeEnv, defaultSpoolMaxAge),
+		ColdStartDimension:      l.boolOrDefault(coldStartDimensionEnv, defaultColdStartDimension),
+		Percentiles:             l.percentilesOrDefault(percentilesEnv, defaultPercentiles),
+		DeadlineMargin:          time.Duration(l.intOrDefault(deadlineMarginEnv, defaultDeadlineMarginMs)) * time.Millisecond,
+		ConfigFile:              configFile,
+		Dimensions:              l.dimensions(),
+		DimensionsInclude:       l.dimensionNames(dimensionsIncludeEnv),
+		DimensionsExclude:       l.dimensionNames(dimensionsExcludeEnv),
+		DimensionsRename:        l.dimensionRenames(),
+		TracesEnabled:           l.boolOrDefault(tracesEnabledEnv, defaultTracesEnabled),
+		TracesExporter:          strings.ToLower(l.strOrDefault(tracesExporterEnv, defaultTracesExporter)),
+		StrictConfig:            l.boolOrDefault(strictConfigEnv, defaultStrictConfig),
 	}
 
 	if configuration.SplunkMetricsUrl == "" && configuration.SplunkRealm != "" {
 		configuration.SplunkMetricsUrl = fmt.Sprintf(ingestUrlFormat, configuration.SplunkRealm)
 	}
 
-	if configuration.SplunkMetricsUrl == "" {
-		log.Println("[ERROR] SPLUNK_REALM is set, but SPLUNK_ACCESS_TOKEN is not set. To export data to Splunk Observability Cloud, define a Splunk Access Token.")
-	} else {
-		configuration.SplunkMetricsUrl += "/v2/datapoint"
+	if configuration.SplunkMetricsUrl != "" {
+		configuration.SplunkMetricsUrl += signalFxDatapointPath
 	}
 
-	if configuration.SplunkRealm != "" && configuration.SplunkToken == "" {
-		log.Println("[ERROR] Exporter endpoint must be set when SPLUNK_REALM is not set. To export data, set either a realm and access token or a custom exporter endpoint.")
-	}
+	configuration.SplunkToken = l.resolveToken(tokenEnv, configuration.SplunkToken)
+
+	configuration.TracesUrl = configuration.tracesUrl(l)
+
+	configuration.Destinations = append([]Destination{configuration.primaryDestination()},
+		configuration.additionalDestinations(l, l.strOrDefault(destinationsEnv, ""))...)
+
+	configuration.parseErrors = l.parseErrors
 
 	return configuration
 }
@@ -107,10 +214,66 @@ func (c Configuration) String() string {
 	addLine("Verbose                = %v", c.Verbose)
 	addLine("HTTP Tracing           = %v", c.HttpTracing)
 	addLine("InsecureSkipHTTPSVerify= %v", c.InsecureSkipHTTPSVerify)
+	addLine("Telemetry Enabled      = %v", c.TelemetryEnabled)
+	addLine("Telemetry Listener     = %v", c.TelemetryListener)
+	addLine("Custom Metrics Enabled = %v", c.CustomMetricsEnabled)
+	addLine("Custom Metrics Listener= %v", c.CustomMetricsListener)
+	addLine("StatsD Enabled         = %v", c.StatsdEnabled)
+	addLine("StatsD Listener        = %v", c.StatsdListener)
+	addLine("EMF Enabled            = %v", c.EmfEnabled)
+	addLine("Metrics Exporter       = %v", c.MetricsExporter)
+	addLine("OTLP Metrics URL       = %v", c.OtlpMetricsUrl)
+	addLine("Prometheus Remote Write= %v", c.RemoteWriteUrl)
+	addLine("Spool Enabled          = %v", c.SpoolEnabled)
+	addLine("Spool Dir              = %v", c.SpoolDir)
+	addLine("Spool Max Bytes        = %v", c.SpoolMaxBytes)
+	addLine("Spool Max Age          = %v", c.SpoolMaxAge.Seconds())
+	addLine("Cold Start Dimension   = %v", c.ColdStartDimension)
+	addLine("Percentiles            = %v", c.Percentiles)
+	addLine("Deadline Margin        = %v", c.DeadlineMargin)
+	addLine("Config File            = %v", c.ConfigFile)
+	addLine("Dimensions             = %v", c.Dimensions)
+	addLine("Dimensions Include     = %v", c.DimensionsInclude)
+	addLine("Dimensions Exclude     = %v", c.DimensionsExclude)
+	addLine("Dimensions Rename      = %v", c.DimensionsRename)
+	addLine("Traces Enabled         = %v", c.TracesEnabled)
+	addLine("Traces Exporter        = %v", c.TracesExporter)
+	addLine("Traces URL             = %v", c.TracesUrl)
+	addLine("Strict Config          = %v", c.StrictConfig)
+	for i := 1; i < len(c.Destinations); i++ {
+		d := c.Destinations[i]
+		addLine("Destination            = %v", fmt.Sprintf("%v (%v) %v, token: %v, timeout: %v, fail fast: %v",
+			d.Name, d.Exporter, d.Url, obfuscatedToken(d.Token), d.Timeout.Seconds(), d.FailFast))
+	}
 
 	return builder.String()
 }
 
+// tracesUrl defaults to the SAPM endpoint of the realm, or to the traces path of the OTLP endpoint
+func (c Configuration) tracesUrl(l *loader) string {
+	switch c.TracesExporter {
+	case TracesExporterOTLP:
+		return l.otlpUrl(otlpTracesPath, tracesEndpointEnv, otlpTracesEndpointEnv)
+	default:
+		realmUrl := ""
+		if c.SplunkRealm != "" {
+			realmUrl = fmt.Sprintf(ingestUrlFormat, c.SplunkRealm) + sapmTracePath
+		}
+		return l.chainOrDefault(realmUrl, fallbackKey{key: tracesEndpointEnv})
+	}
+}
+
+// otlpUrl resolves the signal specific endpoints before the generic OTLP one, which is completed with the path of the signal
+func (l *loader) otlpUrl(path string, keys ...string) string {
+	chain := make([]fallbackKey, 0, len(keys)+1)
+	for _, key := range keys {
+		chain = append(chain, fallbackKey{key: key})
+	}
+	chain = append(chain, fallbackKey{key: otlpEndpointEnv, suffix: path})
+
+	return l.chainOrDefault(defaultOtlpEndpoint+path, chain...)
+}
+
 func obfuscatedToken(token string) string {
 	if len(token) < minTokenLength {
 		return fmt.Sprintf("<invalid token> minimum %v chars required", minTokenLength)
@@ -118,15 +281,60 @@ func obfuscatedToken(token string) string {
 	return fmt.Sprintf("%s...%s", token[0:2], token[len(token)-2:])
 }
 
-func strOrDefault(key, d string) string {
+// resolveToken fetches the token when it's a reference to AWS Secrets Manager or SSM Parameter Store
+func (l *loader) resolveToken(key, token string) string {
+	resolved, err := secrets.Resolve(token)
+	if err != nil {
+		log.Printf("can't fetch the token for key: %s, %v\n", key, err)
+		l.addParseError(key, token, "can't fetch the token: "+err.Error())
+	}
+	return resolved
+}
+
+// loader reads the settings of a single New call, from the environment and from the configuration file
+type loader struct {
+	// the settings of the configuration file keyed by the environment variables, and its dimensions section
+	fileValues     map[string]string
+	fileDimensions map[string]string
+
+	// the values which couldn't be parsed, they are reported by Validate
+	parseErrors []ValidationError
+}
+
+func (l *loader) strOrDefault(key, d string) string {
 	if v, ok := os.LookupEnv(key); ok {
 		return v
 	}
+	if v, ok := l.fileValues[key]; ok {
+		return v
+	}
 	return d
 }
 
-func durationOrDefault(key string, d time.Duration) time.Duration {
-	str := strOrDefault(key, "")
+// fallbackKey is a key of a chain, the suffix completes its value (e.g. the path of the generic OTLP endpoint)
+type fallbackKey struct {
+	key    string
+	suffix string
+}
+
+// chainOrDefault resolves the first key of the chain which is set, the environment variables of the whole chain
+// take precedence over the configuration file, so a fallback variable still wins over a value of the file
+func (l *loader) chainOrDefault(d string, keys ...fallbackKey) string {
+	for _, k := range keys {
+		if v, ok := os.LookupEnv(k.key); ok {
+			return v + k.suffix
+		}
+	}
+	for _, k := range keys {
+		if v, ok := l.fileValues[k.key]; ok {
+			return v + k.suffix
+		}
+	}
+	return d
+}
+
+func (l *loader) durationOrDefault(key string, d time.Duration) time.Duration {
+	str := l.strOrDefault(key, "")
 	if str == "" {
 		return d
 	}
@@ -136,11 +344,46 @@ func durationOrDefault(key string, d time.Duration) time.Duration {
 	}
 
 	log.Printf("can't parse number of seconds for key: %s, %s\n", key, str)
+	l.addParseError(key, str, "not a number of seconds")
 	return d
 }
 
-func boolOrDefault(key string, d bool) bool {
-	str := strOrDefault(key, "")
+func (l *loader) intOrDefault(key string, d int64) int64 {
+	str := l.strOrDefault(key, "")
+	if str == "" {
+		return d
+	}
+
+	if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return i
+	}
+
+	log.Printf("can't parse number for key: %s, %s\n", key, str)
+	l.addParseError(key, str, "not a number")
+	return d
+}
+
+// percentilesOrDefault parses a list of percentiles (above 0, up to 100) separated by commas
+func (l *loader) percentilesOrDefault(key, d string) []float64 {
+	var percentiles []float64
+	for _, str := range strings.Split(l.strOrDefault(key, d), ",") {
+		if str = strings.TrimSpace(str); str == "" {
+			continue
+		}
+
+		p, err := strconv.ParseFloat(str, 64)
+		if err != nil || p <= 0 || p > 100 {
+			log.Printf("can't parse percentile for key: %s, %s\n", key, str)
+			l.addParseError(key, str, "not a percentile")
+			continue
+		}
+		percentiles = append(percentiles, p)
+	}
+	return percentiles
+}
+
+func (l *loader) boolOrDefault(key string, d bool) bool {
+	str := l.strOrDefault(key, "")
 	if str == "" {
 		return d
 	}
@@ -150,5 +393,6 @@ func boolOrDefault(key string, d bool) bool {
 	}
 
 	log.Printf("can't parse bool for key: %s, %s\n", key, str)
+	l.addParseError(key, str, "not a boolean")
 	return d
 }
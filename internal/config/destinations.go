@@ -0,0 +1,128 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	ExporterSignalFx   = "signalfx"
+	ExporterOTLP       = "otlp"
+	ExporterPrometheus = "prometheus"
+)
+
+const (
+	TracesExporterSAPM = "sapm"
+	TracesExporterOTLP = ExporterOTLP
+)
+
+const primaryDestinationName = "default"
+
+const destinationsEnv = "SPLUNK_METRICS_DESTINATIONS"
+
+// Destination is a single endpoint the metrics are sent to, each one is flushed independently.
+type Destination struct {
+	Name     string
+	Exporter string
+	Url      string
+	Token    string
+	Timeout  time.Duration
+	FailFast bool
+}
+
+// jsonDestination is the format of the destinations in SPLUNK_METRICS_DESTINATIONS (a JSON array),
+// the timeout is given in seconds, like REPORTING_TIMEOUT
+type jsonDestination struct {
+	Name     string
+	Exporter string
+	Realm    string
+	Url      string
+	Token    string
+	Timeout  int
+	FailFast bool
+}
+
+// primaryDestination is configured with the original (single destination) environment variables
+func (c Configuration) primaryDestination() Destination {
+	destination := Destination{
+		Name:     primaryDestinationName,
+		Exporter: c.MetricsExporter,
+		Url:      c.SplunkMetricsUrl,
+		Token:    c.SplunkToken,
+		Timeout:  c.ReportingTimeout,
+		FailFast: c.SplunkFailFast,
+	}
+
+	switch c.MetricsExporter {
+	case ExporterOTLP:
+		destination.Url = c.OtlpMetricsUrl
+	case ExporterPrometheus:
+		destination.Url = c.RemoteWriteUrl
+	}
+
+	return destination
+}
+
+func (c Configuration) additionalDestinations(l *loader, str string) []Destination {
+	if str == "" {
+		return nil
+	}
+
+	var decoded []jsonDestination
+	if err := json.Unmarshal([]byte(str), &decoded); err != nil {
+		log.Printf("can't parse destinations for key: %s, %v\n", destinationsEnv, err)
+		l.addParseError(destinationsEnv, "", err.Error())
+		return nil
+	}
+
+	destinations := make([]Destination, 0, len(decoded))
+	for i, d := range decoded {
+		destinations = append(destinations, d.toDestination(l, i, c.ReportingTimeout))
+	}
+	return destinations
+}
+
+func (d jsonDestination) toDestination(l *loader, index int, defaultTimeout time.Duration) Destination {
+	destination := Destination{
+		Name:     d.Name,
+		Exporter: strings.ToLower(d.Exporter),
+		Url:      d.Url,
+		Token:    d.Token,
+		Timeout:  time.Duration(d.Timeout) * time.Second,
+		FailFast: d.FailFast,
+	}
+
+	if destination.Name == "" {
+		destination.Name = fmt.Sprintf("destination-%d", index+1)
+	}
+	if destination.Exporter == "" {
+		destination.Exporter = ExporterSignalFx
+	}
+	if destination.Url == "" && d.Realm != "" && destination.Exporter == ExporterSignalFx {
+		destination.Url = fmt.Sprintf(ingestUrlFormat, d.Realm) + signalFxDatapointPath
+	}
+	if destination.Timeout <= 0 {
+		destination.Timeout = defaultTimeout
+	}
+
+	destination.Token = l.resolveToken("destination "+destination.Name, destination.Token)
+
+	return destination
+}
@@ -0,0 +1,138 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const dimensionsEnv = "SPLUNK_DIMENSIONS"
+const dimensionsIncludeEnv = "SPLUNK_DIMENSIONS_INCLUDE"
+const dimensionsExcludeEnv = "SPLUNK_DIMENSIONS_EXCLUDE"
+const dimensionsRenameEnv = "SPLUNK_DIMENSIONS_RENAME"
+
+const maxDimensionKeyLength = 128
+const maxDimensionValueLength = 256
+const reservedDimensionPrefix = "sf_"
+
+var dimensionKeyPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+
+// dimensions merges the dimensions section of the configuration file with SPLUNK_DIMENSIONS
+// (key:value pairs separated by commas), the environment variable takes precedence
+func (l *loader) dimensions() map[string]string {
+	dims := map[string]string{}
+
+	fromFile := l.fileDimensions
+	keys := make([]string, 0, len(fromFile))
+	for k := range fromFile {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if l.validDimension(configFileEnv, k, fromFile[k]) {
+			dims[k] = fromFile[k]
+		}
+	}
+
+	for _, pair := range strings.Split(l.strOrDefault(dimensionsEnv, ""), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 {
+			log.Printf("can't parse dimension for key: %s, %s\n", dimensionsEnv, pair)
+			l.addParseError(dimensionsEnv, pair, "not a key:value pair")
+			continue
+		}
+
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if l.validDimension(dimensionsEnv, k, v) {
+			dims[k] = v
+		}
+	}
+
+	return dims
+}
+
+// dimensionNames parses a list of dimension names separated by commas
+func (l *loader) dimensionNames(key string) []string {
+	var names []string
+	for _, name := range strings.Split(l.strOrDefault(key, ""), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// dimensionRenames parses old:new pairs separated by commas
+func (l *loader) dimensionRenames() map[string]string {
+	renames := map[string]string{}
+	for _, pair := range l.dimensionNames(dimensionsRenameEnv) {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) != 2 {
+			log.Printf("can't parse dimension rename for key: %s, %s\n", dimensionsRenameEnv, pair)
+			l.addParseError(dimensionsRenameEnv, pair, "not an old:new pair")
+			continue
+		}
+
+		from, to := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if reason := dimensionNameError(to); reason != "" {
+			log.Printf("invalid dimension in %s, %s: %s\n", dimensionsRenameEnv, to, reason)
+			l.addParseError(dimensionsRenameEnv, pair, reason)
+			continue
+		}
+		renames[from] = to
+	}
+	return renames
+}
+
+// dimensionNameError checks the SignalFx rules of the dimension names, it's empty for a valid name
+func dimensionNameError(k string) string {
+	switch {
+	case !dimensionKeyPattern.MatchString(k):
+		return "a dimension name must start with a letter and contain only letters, digits, underscores and hyphens"
+	case len(k) > maxDimensionKeyLength:
+		return fmt.Sprintf("a dimension name can't be longer than %v chars", maxDimensionKeyLength)
+	case strings.HasPrefix(k, reservedDimensionPrefix):
+		return fmt.Sprintf("the dimension names starting with %v are reserved", reservedDimensionPrefix)
+	default:
+		return ""
+	}
+}
+
+// validDimension checks the SignalFx rules of the dimension names and values
+func (l *loader) validDimension(source, k, v string) bool {
+	reason := dimensionNameError(k)
+	switch {
+	case reason != "":
+	case v == "":
+		reason = "a dimension value can't be empty"
+	case len(v) > maxDimensionValueLength:
+		reason = fmt.Sprintf("a dimension value can't be longer than %v chars", maxDimensionValueLength)
+	default:
+		return true
+	}
+
+	log.Printf("invalid dimension in %s, %s: %s\n", source, k, reason)
+	l.addParseError(source, k+":"+v, reason)
+	return false
+}
@@ -0,0 +1,56 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDimensions(t *testing.T) {
+	t.Setenv(dimensionsEnv, "team:payments, env:prod,invalid,sf_metric:x,url:http://localhost")
+
+	l := &loader{fileDimensions: map[string]string{"team": "checkout", "cost-center": "42", "1st": "x"}}
+	dims := l.dimensions()
+
+	expected := map[string]string{"team": "payments", "env": "prod", "cost-center": "42", "url": "http://localhost"}
+	if !reflect.DeepEqual(dims, expected) {
+		t.Errorf("Expected `%v`, got `%v`", expected, dims)
+	}
+
+	keys := map[string]bool{}
+	for _, e := range l.parseErrors {
+		keys[e.Key+" "+e.Value] = true
+	}
+	for _, k := range []string{configFileEnv + " 1st:x", dimensionsEnv + " invalid", dimensionsEnv + " sf_metric:x"} {
+		if !keys[k] {
+			t.Errorf("Expected an error of `%v`, got `%v`", k, l.parseErrors)
+		}
+	}
+}
+
+func TestDimensionRenames(t *testing.T) {
+	t.Setenv(dimensionsRenameEnv, "aws_function_name:function, AWSUniqueId, aws_arn:sf_arn")
+
+	l := &loader{}
+	renames := l.dimensionRenames()
+
+	if !reflect.DeepEqual(renames, map[string]string{"aws_function_name": "function"}) {
+		t.Errorf("Unexpected renames: %v", renames)
+	}
+	if len(l.parseErrors) != 2 {
+		t.Errorf("Expected `%v`, got `%v`", 2, l.parseErrors)
+	}
+}
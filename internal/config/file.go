@@ -0,0 +1,155 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+const configFileEnv = "SPLUNK_CONFIG_FILE"
+const defaultConfigFile = "/opt/splunk-extension.yaml"
+
+const destinationsKey = "destinations"
+const dimensionsKey = "dimensions"
+
+// fileKeys maps the keys of the configuration file to the environment variables they stand for,
+// an environment variable takes precedence over the same setting in the file
+var fileKeys = map[string]string{
+	"realm":                    realmEnv,
+	"metricsEndpoint":          ingestURLEnv,
+	"accessToken":              tokenEnv,
+	"fastIngest":               fastIngestEnv,
+	"reportingRate":            reportingDelayEnv,
+	"reportingTimeout":         reportingTimeoutEnv,
+	"verbose":                  verboseEnv,
+	"httpTracing":              httpTracingEnv,
+	"failFast":                 failFastEnv,
+	"insecureSkipHttpsVerify":  insecureSkipHTTPSVerifyEnv,
+	"telemetryEnabled":         telemetryEnabledEnv,
+	"telemetryListener":        telemetryListenerEnv,
+	"customMetricsEnabled":     customMetricsEnabledEnv,
+	"customMetricsListener":    customMetricsListenerEnv,
+	"statsdEnabled":            statsdEnabledEnv,
+	"statsdListener":           statsdListenerEnv,
+	"emfEnabled":               emfEnabledEnv,
+	"metricsExporter":          metricsExporterEnv,
+	"otlpEndpoint":             otlpEndpointEnv,
+	"otlpMetricsEndpoint":      otlpMetricsEndpointEnv,
+	"prometheusRemoteWriteUrl": prometheusRemoteWriteUrlEnv,
+	"spoolEnabled":             spoolEnabledEnv,
+	"spoolDir":                 spoolDirEnv,
+	"spoolMaxBytes":            spoolMaxBytesEnv,
+	"spoolMaxAge":              spoolMaxAgeEnv,
+	"coldStartDimension":       coldStartDimensionEnv,
+	"percentiles":              percentilesEnv,
+	"deadlineMarginMs":         deadlineMarginEnv,
+	"dimensionsInclude":        dimensionsIncludeEnv,
+	"dimensionsExclude":        dimensionsExcludeEnv,
+	"dimensionsRename":         dimensionsRenameEnv,
+	"tracesEnabled":            tracesEnabledEnv,
+	"tracesExporter":           tracesExporterEnv,
+	"tracesEndpoint":           tracesEndpointEnv,
+	"otlpTracesEndpoint":       otlpTracesEndpointEnv,
+	"strictConfig":             strictConfigEnv,
+}
+
+// loadFile reads the YAML (or JSON, which is a subset of YAML) configuration file,
+// a missing file is only reported when its path was set explicitly
+func (l *loader) loadFile() string {
+	l.fileValues = map[string]string{}
+	l.fileDimensions = map[string]string{}
+
+	path, explicit := os.LookupEnv(configFileEnv)
+	if !explicit {
+		path = defaultConfigFile
+	}
+	if path == "" {
+		return ""
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if explicit || !os.IsNotExist(err) {
+			log.Printf("can't read the configuration file: %v\n", err)
+			l.addParseError(configFileEnv, path, err.Error())
+		}
+		return ""
+	}
+
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(content, &settings); err != nil {
+		log.Printf("can't parse the configuration file %v: %v\n", path, err)
+		l.addParseError(configFileEnv, path, err.Error())
+		return ""
+	}
+
+	for key, value := range settings {
+		switch key {
+		case destinationsKey:
+			// the destinations share the format of SPLUNK_METRICS_DESTINATIONS
+			if encoded, err := json.Marshal(value); err == nil {
+				l.fileValues[destinationsEnv] = string(encoded)
+			} else {
+				log.Printf("can't read the destinations of the configuration file: %v\n", err)
+			}
+		case dimensionsKey:
+			dims, ok := value.(map[string]interface{})
+			if !ok {
+				log.Printf("the dimensions of the configuration file must be a map, got: %v\n", value)
+				continue
+			}
+			for k, v := range dims {
+				l.fileDimensions[k] = fmt.Sprint(v)
+			}
+		default:
+			if env, found := fileKeys[key]; found && value != nil {
+				l.fileValues[env] = fileValue(value)
+			} else if !found {
+				log.Printf("unknown key in the configuration file: %v\n", key)
+			}
+		}
+	}
+
+	return path
+}
+
+// fileValue formats the value like the environment variable would be set,
+// lists are separated by commas and maps are key:value pairs separated by commas
+func fileValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for k, item := range v {
+			pairs = append(pairs, k+":"+fmt.Sprint(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testConfigFile = `
+realm: us1
+accessToken: file-token-0123456789
+reportingRate: 30
+statsdEnabled: true
+destinations:
+  - name: backup
+    exporter: otlp
+    url: http://localhost:4318/v1/metrics
+    timeout: 2
+dimensions:
+  team: payments
+  env: prod
+`
+
+func TestConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "splunk-extension.yaml")
+	if err := ioutil.WriteFile(path, []byte(testConfigFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(configFileEnv, path)
+	t.Setenv(realmEnv, "eu0")
+
+	c := New()
+
+	if c.ConfigFile != path {
+		t.Errorf("Expected `%v`, got `%v`", path, c.ConfigFile)
+	}
+	if c.SplunkRealm != "eu0" {
+		t.Errorf("Expected the environment to take precedence, got `%v`", c.SplunkRealm)
+	}
+	if c.SplunkToken != "file-token-0123456789" {
+		t.Errorf("Expected `%v`, got `%v`", "file-token-0123456789", c.SplunkToken)
+	}
+	if c.ReportingDelay != 30*time.Second {
+		t.Errorf("Expected `%v`, got `%v`", 30*time.Second, c.ReportingDelay)
+	}
+	if !c.StatsdEnabled {
+		t.Errorf("Expected StatsD to be enabled")
+	}
+
+	if len(c.Destinations) != 2 {
+		t.Fatalf("Expected `%v`, got `%v`", 2, len(c.Destinations))
+	}
+	if d := c.Destinations[1]; d.Name != "backup" || d.Exporter != ExporterOTLP || d.Timeout != 2*time.Second {
+		t.Errorf("Unexpected destination: %+v", d)
+	}
+
+	if c.Dimensions["team"] != "payments" || c.Dimensions["env"] != "prod" {
+		t.Errorf("Unexpected dimensions: %v", c.Dimensions)
+	}
+}
+
+func TestMissingConfigFile(t *testing.T) {
+	t.Setenv(configFileEnv, filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv(statsdEnabledEnv, "")
+
+	c := New()
+
+	if c.ConfigFile != "" || c.StatsdEnabled != defaultStatsdEnabled {
+		t.Errorf("Expected the defaults, got `%v`", c)
+	}
+}
+
+func TestFallbackEnvironmentOverConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "splunk-extension.yaml")
+	content := "metricsEndpoint: http://file:9943\notlpMetricsEndpoint: http://file:4318/v1/metrics\ntracesEndpoint: http://file:4318/v1/traces\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(configFileEnv, path)
+	t.Setenv(ingestURLEnvDeprecated, "http://env:9943")
+	t.Setenv(otlpEndpointEnv, "http://env:4318")
+	t.Setenv(tracesExporterEnv, TracesExporterOTLP)
+	t.Setenv(otlpTracesEndpointEnv, "http://env:4318/traces")
+
+	c := New()
+
+	if c.SplunkMetricsUrl != "http://env:9943/v2/datapoint" {
+		t.Errorf("Expected `%v`, got `%v`", "http://env:9943/v2/datapoint", c.SplunkMetricsUrl)
+	}
+	if c.OtlpMetricsUrl != "http://env:4318/v1/metrics" {
+		t.Errorf("Expected `%v`, got `%v`", "http://env:4318/v1/metrics", c.OtlpMetricsUrl)
+	}
+	if c.TracesUrl != "http://env:4318/traces" {
+		t.Errorf("Expected `%v`, got `%v`", "http://env:4318/traces", c.TracesUrl)
+	}
+}
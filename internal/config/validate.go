@@ -0,0 +1,144 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidationError is a single problem of the configuration, Key names the setting it comes from
+type ValidationError struct {
+	Key    string
+	Value  string
+	Reason string
+}
+
+func (e ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%v: %v", e.Key, e.Reason)
+	}
+	return fmt.Sprintf("%v=%v: %v", e.Key, e.Value, e.Reason)
+}
+
+// ValidationErrors are all the problems found by Validate
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (l *loader) addParseError(key, value, reason string) {
+	l.parseErrors = append(l.parseErrors, ValidationError{Key: key, Value: value, Reason: reason})
+}
+
+// Validate returns ValidationErrors when the configuration can't be used to send the metrics
+func (c Configuration) Validate() error {
+	errs := append(ValidationErrors{}, c.parseErrors...)
+
+	if c.SplunkRealm != "" && c.SplunkToken == "" {
+		errs = append(errs, ValidationError{Key: tokenEnv,
+			Reason: "SPLUNK_REALM is set, but SPLUNK_ACCESS_TOKEN is not set. To export data to Splunk Observability Cloud, define a Splunk Access Token."})
+	}
+
+	if c.SplunkRealm != "" && c.SplunkMetricsUrl != "" && c.SplunkMetricsUrl != fmt.Sprintf(ingestUrlFormat, c.SplunkRealm)+signalFxDatapointPath {
+		errs = append(errs, ValidationError{Key: ingestURLEnv, Value: c.SplunkMetricsUrl,
+			Reason: "conflicts with SPLUNK_REALM, set either a realm or a custom exporter endpoint"})
+	}
+
+	for i, d := range c.Destinations {
+		key := "destination " + d.Name
+		if i == 0 {
+			key = c.primaryKey()
+		}
+		errs = append(errs, d.validate(key)...)
+	}
+
+	if c.TracesEnabled {
+		errs = append(errs, c.validateTraces()...)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (c Configuration) validateTraces() []ValidationError {
+	var errs []ValidationError
+
+	switch c.TracesExporter {
+	case TracesExporterSAPM, TracesExporterOTLP:
+	default:
+		errs = append(errs, ValidationError{Key: tracesExporterEnv, Value: c.TracesExporter, Reason: "unknown traces exporter"})
+	}
+
+	if !c.TelemetryEnabled {
+		errs = append(errs, ValidationError{Key: tracesEnabledEnv,
+			Reason: "the spans are built from the Telemetry API, SPLUNK_TELEMETRY_ENABLED must not be false"})
+	}
+
+	if c.TracesUrl == "" {
+		errs = append(errs, ValidationError{Key: tracesEndpointEnv,
+			Reason: "traces endpoint must be set when SPLUNK_REALM is not set"})
+	} else if u, err := url.Parse(c.TracesUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, ValidationError{Key: tracesEndpointEnv, Value: c.TracesUrl, Reason: "invalid URL"})
+	}
+
+	return errs
+}
+
+// primaryKey is the environment variable of the primary destination's URL
+func (c Configuration) primaryKey() string {
+	switch c.MetricsExporter {
+	case ExporterOTLP:
+		return otlpMetricsEndpointEnv
+	case ExporterPrometheus:
+		return prometheusRemoteWriteUrlEnv
+	default:
+		return ingestURLEnv
+	}
+}
+
+func (d Destination) validate(key string) []ValidationError {
+	var errs []ValidationError
+
+	switch d.Exporter {
+	case ExporterSignalFx, ExporterOTLP, ExporterPrometheus:
+	default:
+		errs = append(errs, ValidationError{Key: key, Value: d.Exporter, Reason: "unknown metrics exporter"})
+	}
+
+	if d.Url == "" && d.Exporter == ExporterSignalFx {
+		errs = append(errs, ValidationError{Key: key,
+			Reason: "Exporter endpoint must be set when SPLUNK_REALM is not set. To export data, set either a realm and access token or a custom exporter endpoint."})
+	} else if d.Url == "" {
+		errs = append(errs, ValidationError{Key: key, Reason: "exporter endpoint must be set"})
+	} else if u, err := url.Parse(d.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, ValidationError{Key: key, Value: d.Url, Reason: "invalid URL"})
+	}
+
+	if d.Token != "" && len(d.Token) < minTokenLength {
+		errs = append(errs, ValidationError{Key: key,
+			Reason: fmt.Sprintf("token too short, minimum %v chars required", minTokenLength)})
+	}
+
+	return errs
+}
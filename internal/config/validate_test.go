@@ -0,0 +1,135 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Setenv(configFileEnv, "")
+	t.Setenv(realmEnv, "us0")
+	t.Setenv(ingestURLEnv, "ftp://localhost")
+	t.Setenv(tokenEnv, "short")
+	t.Setenv(reportingTimeoutEnv, "5s")
+	t.Setenv(destinationsEnv, `[{"name": "backup", "exporter": "zipkin", "url": "http://localhost:9411"}]`)
+
+	err := New().Validate()
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got `%v`", err)
+	}
+
+	expected := []ValidationError{
+		{Key: reportingTimeoutEnv, Value: "5s", Reason: "not a number of seconds"},
+		{Key: ingestURLEnv, Value: "ftp://localhost/v2/datapoint", Reason: "conflicts with SPLUNK_REALM, set either a realm or a custom exporter endpoint"},
+		{Key: ingestURLEnv, Value: "ftp://localhost/v2/datapoint", Reason: "invalid URL"},
+		{Key: ingestURLEnv, Reason: "token too short, minimum 10 chars required"},
+		{Key: "destination backup", Value: "zipkin", Reason: "unknown metrics exporter"},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected `%v`, got `%v`", expected, errs)
+	}
+	for i := range expected {
+		if errs[i] != expected[i] {
+			t.Errorf("Expected `%v`, got `%v`", expected[i], errs[i])
+		}
+	}
+}
+
+func TestParseErrorsOfEachConfiguration(t *testing.T) {
+	t.Setenv(configFileEnv, "")
+	t.Setenv(realmEnv, "us0")
+	t.Setenv(ingestURLEnv, "")
+	t.Setenv(tokenEnv, "test-token-0123456789")
+	t.Setenv(destinationsEnv, "")
+
+	t.Setenv(reportingTimeoutEnv, "5s")
+	invalid := New()
+	t.Setenv(reportingTimeoutEnv, "5")
+	valid := New()
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected no errors, got `%v`", err)
+	}
+	if err := invalid.Validate(); err == nil || err.Error() != "REPORTING_TIMEOUT=5s: not a number of seconds" {
+		t.Errorf("Expected `%v`, got `%v`", "REPORTING_TIMEOUT=5s: not a number of seconds", err)
+	}
+}
+
+func TestValidConfiguration(t *testing.T) {
+	t.Setenv(configFileEnv, "")
+	t.Setenv(realmEnv, "us0")
+	t.Setenv(ingestURLEnv, "")
+	t.Setenv(tokenEnv, "test-token-0123456789")
+	t.Setenv(destinationsEnv, "")
+
+	if err := New().Validate(); err != nil {
+		t.Errorf("Expected no errors, got `%v`", err)
+	}
+}
+
+func TestValidateTraces(t *testing.T) {
+	t.Setenv(configFileEnv, "")
+	t.Setenv(realmEnv, "")
+	t.Setenv(ingestURLEnv, "http://localhost:9943")
+	t.Setenv(tokenEnv, "")
+	t.Setenv(destinationsEnv, "")
+	t.Setenv(tracesEnabledEnv, "true")
+	t.Setenv(tracesExporterEnv, "zipkin")
+	t.Setenv(tracesEndpointEnv, "")
+	t.Setenv(telemetryEnabledEnv, "false")
+
+	err := New().Validate()
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got `%v`", err)
+	}
+
+	expected := []ValidationError{
+		{Key: tracesExporterEnv, Value: "zipkin", Reason: "unknown traces exporter"},
+		{Key: tracesEnabledEnv, Reason: "the spans are built from the Telemetry API, SPLUNK_TELEMETRY_ENABLED must not be false"},
+		{Key: tracesEndpointEnv, Reason: "traces endpoint must be set when SPLUNK_REALM is not set"},
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected `%v`, got `%v`", expected, errs)
+	}
+	for i := range expected {
+		if errs[i] != expected[i] {
+			t.Errorf("Expected `%v`, got `%v`", expected[i], errs[i])
+		}
+	}
+}
+
+func TestTracesUrl(t *testing.T) {
+	t.Setenv(configFileEnv, "")
+	t.Setenv(realmEnv, "us0")
+	t.Setenv(otlpEndpointEnv, "http://localhost:4318")
+
+	t.Setenv(tracesExporterEnv, "")
+	if url := New().TracesUrl; url != "https://ingest.us0.signalfx.com/v2/trace/sapm" {
+		t.Errorf("Expected `%v`, got `%v`", "https://ingest.us0.signalfx.com/v2/trace/sapm", url)
+	}
+
+	t.Setenv(tracesExporterEnv, "otlp")
+	if url := New().TracesUrl; url != "http://localhost:4318/v1/traces" {
+		t.Errorf("Expected `%v`, got `%v`", "http://localhost:4318/v1/traces", url)
+	}
+}
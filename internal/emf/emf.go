@@ -0,0 +1,167 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package emf translates CloudWatch Embedded Metric Format documents into SignalFx datapoints.
+// See https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+package emf
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"strings"
+	"time"
+)
+
+const metadataKey = "_aws"
+
+// the CloudWatch namespace is kept as a dimension, the same way the SignalFx AWS integration does
+const dimNamespace = "namespace"
+
+type metricDefinition struct {
+	Name string
+	Unit string
+}
+
+type metricDirective struct {
+	Namespace  string
+	Dimensions [][]string
+	Metrics    []metricDefinition
+}
+
+type metadata struct {
+	Timestamp         int64
+	CloudWatchMetrics []metricDirective
+}
+
+// FromLogRecord finds an EMF document in the record of a "function" telemetry event.
+// The record is either a plain text line or, with the JSON log format, an object
+// which is the document itself or carries the line in its "message".
+func FromLogRecord(record json.RawMessage) ([]byte, bool) {
+	var line string
+	if err := json.Unmarshal(record, &line); err == nil {
+		return fromLine(line)
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(record, &object); err != nil {
+		return nil, false
+	}
+
+	if _, found := object[metadataKey]; found {
+		return record, true
+	}
+
+	if message, found := object["message"]; found && json.Unmarshal(message, &line) == nil {
+		return fromLine(line)
+	}
+
+	return nil, false
+}
+
+// runtimes may prefix the line with a timestamp, request id and log level
+func fromLine(line string) ([]byte, bool) {
+	start := strings.Index(line, "{")
+	if start < 0 || !strings.Contains(line[start:], `"`+metadataKey+`"`) {
+		return nil, false
+	}
+	return []byte(strings.TrimSpace(line[start:])), true
+}
+
+// Parse returns a gauge for every metric in every dimension set declared by the document.
+// Metrics with several values are reported as their mean.
+func Parse(document []byte) ([]*datapoint.Datapoint, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(document, &members); err != nil {
+		return nil, fmt.Errorf("not a JSON object: %v", err)
+	}
+
+	rawMetadata, found := members[metadataKey]
+	if !found {
+		return nil, fmt.Errorf("%v member is missing", metadataKey)
+	}
+
+	var meta metadata
+	if err := json.Unmarshal(rawMetadata, &meta); err != nil {
+		return nil, fmt.Errorf("invalid %v member: %v", metadataKey, err)
+	}
+
+	var timestamp time.Time
+	if meta.Timestamp > 0 {
+		timestamp = time.Unix(0, meta.Timestamp*int64(time.Millisecond))
+	}
+
+	var dps []*datapoint.Datapoint
+	for _, directive := range meta.CloudWatchMetrics {
+		dimensionSets := directive.Dimensions
+		if len(dimensionSets) == 0 {
+			dimensionSets = [][]string{{}}
+		}
+
+		for _, dimensionSet := range dimensionSets {
+			dims, err := dimensions(members, directive.Namespace, dimensionSet)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, metric := range directive.Metrics {
+				value, err := metricValue(members, metric.Name)
+				if err != nil {
+					return nil, err
+				}
+				dps = append(dps, datapoint.New(metric.Name, dims, datapoint.NewFloatValue(value), datapoint.Gauge, timestamp))
+			}
+		}
+	}
+
+	return dps, nil
+}
+
+func dimensions(members map[string]json.RawMessage, namespace string, dimensionSet []string) (map[string]string, error) {
+	dims := map[string]string{dimNamespace: namespace}
+
+	for _, name := range dimensionSet {
+		var value string
+		raw, found := members[name]
+		if !found || json.Unmarshal(raw, &value) != nil {
+			return nil, fmt.Errorf("dimension %v must be a string member", name)
+		}
+		dims[name] = value
+	}
+
+	return dims, nil
+}
+
+func metricValue(members map[string]json.RawMessage, name string) (float64, error) {
+	raw, found := members[name]
+	if !found {
+		return 0, fmt.Errorf("value of metric %v is missing", name)
+	}
+
+	var value float64
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+
+	var values []float64
+	if err := json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+		return 0, fmt.Errorf("value of metric %v must be a number or an array of numbers", name)
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values)), nil
+}
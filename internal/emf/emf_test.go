@@ -0,0 +1,80 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const document = `{
+	"_aws": {
+		"Timestamp": 1574109732004,
+		"CloudWatchMetrics": [{
+			"Namespace": "orders",
+			"Dimensions": [["service"], ["service", "operation"]],
+			"Metrics": [{"Name": "latency", "Unit": "Milliseconds"}, {"Name": "items"}]
+		}]
+	},
+	"service": "checkout",
+	"operation": "submit",
+	"latency": 100,
+	"items": [1, 2, 6]
+}`
+
+func TestParse(t *testing.T) {
+	dps, err := Parse([]byte(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dps) != 4 {
+		t.Fatalf("Expected 4 datapoints, got %v", len(dps))
+	}
+
+	for _, dp := range dps {
+		if dp.Dimensions[dimNamespace] != "orders" || dp.Dimensions["service"] != "checkout" {
+			t.Errorf("Unexpected dimensions: %v", dp.Dimensions)
+		}
+		if dp.Timestamp.UnixNano() != 1574109732004000000 {
+			t.Errorf("Unexpected timestamp: %v", dp.Timestamp)
+		}
+	}
+
+	if _, found := dps[0].Dimensions["operation"]; found {
+		t.Errorf("Expected only the dimensions of the first set, got %v", dps[0].Dimensions)
+	}
+	if dps[2].Dimensions["operation"] != "submit" {
+		t.Errorf("Expected the dimensions of the second set, got %v", dps[2].Dimensions)
+	}
+	if dps[1].Metric != "items" || dps[1].Value.String() != "3" {
+		t.Errorf("Expected the mean of the values, got %v", dps[1])
+	}
+}
+
+func TestFromLogRecord(t *testing.T) {
+	line, _ := json.Marshal("2024-01-01T00:00:00.000Z\tc0ffee\tINFO\t" + `{"_aws": {}, "x": 1}`)
+	if doc, found := FromLogRecord(line); !found || string(doc) != `{"_aws": {}, "x": 1}` {
+		t.Errorf("Expected the document to be found in a text line, got %q", doc)
+	}
+
+	if _, found := FromLogRecord([]byte(`"just a log line"`)); found {
+		t.Errorf("Expected no document in a plain log line")
+	}
+
+	if _, found := FromLogRecord([]byte(`{"_aws": {}, "x": 1}`)); !found {
+		t.Errorf("Expected the JSON record to be the document")
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package emulator
+
+import (
+	"compress/gzip"
+	"fmt"
+	sfxmodel "github.com/signalfx/com_signalfx_metrics_protobuf/model"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+const datapointPath = "/v2/datapoint"
+
+// Datapoint is a datapoint as it was received by the ingest
+type Datapoint struct {
+	Metric     string
+	MetricType string
+	Dimensions map[string]string
+	Value      float64
+	Timestamp  int64
+}
+
+// Ingest accepts the datapoints in the SignalFx protobuf format, the way the ingest does.
+type Ingest struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu         sync.Mutex
+	datapoints []Datapoint
+	tokens     []string
+}
+
+func NewIngest() (*Ingest, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	ingest := &Ingest{listener: listener}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(datapointPath, ingest.receive)
+
+	ingest.server = &http.Server{Handler: mux}
+	go func() {
+		if err := ingest.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("emulated ingest stopped: %v\n", err)
+		}
+	}()
+
+	return ingest, nil
+}
+
+// Url is the value of SPLUNK_METRICS_ENDPOINT
+func (ingest *Ingest) Url() string {
+	return "http://" + ingest.listener.Addr().String()
+}
+
+func (ingest *Ingest) Close() {
+	_ = ingest.server.Close()
+}
+
+func (ingest *Ingest) Datapoints() []Datapoint {
+	ingest.mu.Lock()
+	defer ingest.mu.Unlock()
+	return append([]Datapoint(nil), ingest.datapoints...)
+}
+
+// Tokens are the access tokens the datapoints were sent with
+func (ingest *Ingest) Tokens() []string {
+	ingest.mu.Lock()
+	defer ingest.mu.Unlock()
+	return append([]string(nil), ingest.tokens...)
+}
+
+// Sum adds up the values of the metric of all the datapoints having the given dimensions
+func (ingest *Ingest) Sum(metric string, dims map[string]string) float64 {
+	sum := 0.0
+	for _, dp := range ingest.Datapoints() {
+		if dp.Metric == metric && hasDimensions(dp, dims) {
+			sum += dp.Value
+		}
+	}
+	return sum
+}
+
+func hasDimensions(dp Datapoint, dims map[string]string) bool {
+	for k, v := range dims {
+		if dp.Dimensions[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (ingest *Ingest) receive(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	}
+
+	bytes, err := ioutil.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	message := &sfxmodel.DataPointUploadMessage{}
+	if err := message.Unmarshal(bytes); err != nil {
+		http.Error(w, fmt.Sprintf("invalid protobuf: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	ingest.mu.Lock()
+	ingest.tokens = append(ingest.tokens, r.Header.Get("X-Sf-Token"))
+	for _, dp := range message.Datapoints {
+		ingest.datapoints = append(ingest.datapoints, fromProtobuf(dp))
+	}
+	ingest.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`"OK"`))
+}
+
+func fromProtobuf(dp *sfxmodel.DataPoint) Datapoint {
+	dims := map[string]string{}
+	for _, dim := range dp.Dimensions {
+		dims[dim.Key] = dim.Value
+	}
+
+	value := 0.0
+	switch {
+	case dp.Value.IntValue != nil:
+		value = float64(*dp.Value.IntValue)
+	case dp.Value.DoubleValue != nil:
+		value = *dp.Value.DoubleValue
+	}
+
+	metricType := sfxmodel.MetricType_GAUGE
+	if dp.MetricType != nil {
+		metricType = *dp.MetricType
+	}
+
+	return Datapoint{
+		Metric:     dp.Metric,
+		MetricType: metricType.String(),
+		Dimensions: dims,
+		Value:      value,
+		Timestamp:  dp.Timestamp,
+	}
+}
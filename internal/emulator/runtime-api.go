@@ -0,0 +1,387 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package emulator provides local stand-ins for the Lambda Runtime API and for the SignalFx ingest,
+// so the whole extension can be run outside of Lambda (in tests or by the simulate command).
+package emulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	extensionId         = "emulated-extension-id"
+	defaultTimeout      = 3 * time.Second
+	defaultStatus       = "success"
+	defaultShutdown     = "spindown"
+	initializationType  = "on-demand"
+	memorySizeMB        = 128
+	maxMemoryUsedMB     = 64
+	registerPath        = "/2020-01-01/extension/register"
+	nextPath            = "/2020-01-01/extension/event/next"
+	initErrorPath       = "/2020-01-01/extension/init/error"
+	exitErrorPath       = "/2020-01-01/extension/exit/error"
+	telemetryPath       = "/2022-07-01/telemetry"
+	errorTypeHeader     = "Lambda-Extension-Function-Error-Type"
+	identifierHeader    = "Lambda-Extension-Identifier"
+	extensionNameHeader = "Lambda-Extension-Name"
+)
+
+// Invocation is an INVOKE event, followed by its telemetry: platform.runtimeDone is delivered once the duration passed,
+// like the function was running, and platform.report when the extension asks for the next event.
+type Invocation struct {
+	FunctionArn string
+	RequestId   string
+	Duration    time.Duration
+	Timeout     time.Duration
+	Status      string
+}
+
+// Step of a scenario is either an invocation, a pause (the environment being frozen) or the shutdown.
+type Step struct {
+	Invoke   *Invocation
+	Freeze   time.Duration
+	Shutdown string
+}
+
+type Subscription struct {
+	URI   string
+	Types []string
+}
+
+// RuntimeApi serves the Extensions and Telemetry API endpoints, scripted by the scenario steps.
+// Once the steps are exhausted, the SHUTDOWN event is sent.
+type RuntimeApi struct {
+	FunctionName    string
+	FunctionVersion string
+
+	listener net.Listener
+	server   *http.Server
+
+	mu           sync.Mutex
+	startedAt    time.Time
+	initReported bool
+	steps        []Step
+	pending      *Invocation
+	pendingStart time.Time
+	pendingDone  chan struct{}
+	registered   []string
+	subscription *Subscription
+	errors       []string
+	requests     int
+}
+
+func NewRuntimeApi(functionName, functionVersion string, steps []Step) (*RuntimeApi, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	api := &RuntimeApi{
+		FunctionName:    functionName,
+		FunctionVersion: functionVersion,
+		listener:        listener,
+		startedAt:       time.Now(),
+		steps:           steps,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(registerPath, api.register)
+	mux.HandleFunc(nextPath, api.next)
+	mux.HandleFunc(initErrorPath, api.reportedError)
+	mux.HandleFunc(exitErrorPath, api.reportedError)
+	mux.HandleFunc(telemetryPath, api.subscribe)
+
+	api.server = &http.Server{Handler: mux}
+	go func() {
+		if err := api.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("emulated runtime API stopped: %v\n", err)
+		}
+	}()
+
+	return api, nil
+}
+
+// Host is the value of AWS_LAMBDA_RUNTIME_API
+func (api *RuntimeApi) Host() string {
+	return api.listener.Addr().String()
+}
+
+func (api *RuntimeApi) Close() {
+	_ = api.server.Close()
+}
+
+// RegisteredEvents are the event types the extension registered for
+func (api *RuntimeApi) RegisteredEvents() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.registered
+}
+
+func (api *RuntimeApi) Subscription() *Subscription {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.subscription
+}
+
+// Errors are the error types reported by the extension via init/error and exit/error
+func (api *RuntimeApi) Errors() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.errors
+}
+
+func (api *RuntimeApi) register(w http.ResponseWriter, r *http.Request) {
+	var body struct{ Events []string }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Header.Get(extensionNameHeader) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	api.mu.Lock()
+	api.registered = body.Events
+	api.mu.Unlock()
+
+	w.Header().Set(identifierHeader, extensionId)
+	writeJson(w, map[string]string{
+		"functionName":    api.FunctionName,
+		"functionVersion": api.FunctionVersion,
+		"handler":         "index.handler",
+	})
+}
+
+func (api *RuntimeApi) subscribe(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Types       []string
+		Destination struct{ URI string }
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Header.Get(identifierHeader) != extensionId {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	api.mu.Lock()
+	api.subscription = &Subscription{URI: body.Destination.URI, Types: body.Types}
+	api.mu.Unlock()
+
+	writeJson(w, "OK")
+}
+
+func (api *RuntimeApi) reportedError(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	api.errors = append(api.errors, r.Header.Get(errorTypeHeader))
+	api.mu.Unlock()
+
+	writeJson(w, map[string]string{"status": "OK"})
+}
+
+func (api *RuntimeApi) next(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(identifierHeader) != extensionId {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	api.deliverTelemetry()
+
+	for {
+		step, found := api.nextStep()
+
+		switch {
+		case !found:
+			writeJson(w, shutdownEvent(defaultShutdown))
+			return
+		case step.Freeze > 0:
+			time.Sleep(step.Freeze)
+		case step.Invoke != nil:
+			writeJson(w, api.invoke(*step.Invoke))
+			return
+		case step.Shutdown != "":
+			writeJson(w, shutdownEvent(step.Shutdown))
+			return
+		}
+	}
+}
+
+func (api *RuntimeApi) nextStep() (Step, bool) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if len(api.steps) == 0 {
+		return Step{}, false
+	}
+
+	step := api.steps[0]
+	api.steps = api.steps[1:]
+	return step, true
+}
+
+func (api *RuntimeApi) invoke(invocation Invocation) map[string]interface{} {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.requests++
+	if invocation.RequestId == "" {
+		invocation.RequestId = fmt.Sprintf("emulated-request-%d", api.requests)
+	}
+	if invocation.Timeout == 0 {
+		invocation.Timeout = defaultTimeout
+	}
+	if invocation.Status == "" {
+		invocation.Status = defaultStatus
+	}
+
+	api.pending = &invocation
+	api.pendingStart = time.Now()
+	api.pendingDone = make(chan struct{})
+
+	go api.runFunction(invocation, api.pendingStart, api.subscription, api.pendingDone)
+
+	return map[string]interface{}{
+		"eventType":          "INVOKE",
+		"deadlineMs":         api.pendingStart.Add(invocation.Timeout).UnixNano() / int64(time.Millisecond),
+		"requestId":          invocation.RequestId,
+		"invokedFunctionArn": invocation.FunctionArn,
+	}
+}
+
+// runFunction delivers platform.runtimeDone when the function would be done with the invocation
+func (api *RuntimeApi) runFunction(invocation Invocation, start time.Time, subscription *Subscription, done chan struct{}) {
+	defer close(done)
+
+	time.Sleep(time.Until(start.Add(invocation.Duration)))
+	if subscription != nil {
+		deliver(subscription, []map[string]interface{}{runtimeDoneEvent(&invocation, start)})
+	}
+}
+
+// deliverTelemetry pushes the telemetry of the initialization (once) and the report of the last invocation to the subscriber,
+// the report always follows platform.runtimeDone
+func (api *RuntimeApi) deliverTelemetry() {
+	api.mu.Lock()
+	invocation, start, done, subscription := api.pending, api.pendingStart, api.pendingDone, api.subscription
+	api.pending = nil
+	reportInit := subscription != nil && !api.initReported
+	if reportInit {
+		api.initReported = true
+	}
+	api.mu.Unlock()
+
+	if subscription == nil {
+		return
+	}
+
+	var events []map[string]interface{}
+	if reportInit {
+		events = append(events, api.initEvents()...)
+	}
+	if invocation != nil {
+		<-done
+		events = append(events, reportEvent(invocation, start))
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	deliver(subscription, events)
+}
+
+func deliver(subscription *Subscription, events []map[string]interface{}) {
+	body, _ := json.Marshal(events)
+	resp, err := http.Post(subscription.URI, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("can't deliver telemetry: %v\n", err)
+		return
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+}
+
+// initEvents report the initialization as lasting from the start of the emulator until now
+func (api *RuntimeApi) initEvents() []map[string]interface{} {
+	end := time.Now()
+	durationMs := float64(end.Sub(api.startedAt)) / float64(time.Millisecond)
+
+	return []map[string]interface{}{
+		{
+			"time":   api.startedAt.Format(time.RFC3339Nano),
+			"type":   "platform.initStart",
+			"record": map[string]interface{}{"initializationType": initializationType, "phase": "init"},
+		},
+		{
+			"time":   end.Format(time.RFC3339Nano),
+			"type":   "platform.initRuntimeDone",
+			"record": map[string]interface{}{"initializationType": initializationType, "phase": "init", "status": defaultStatus},
+		},
+		{
+			"time": end.Format(time.RFC3339Nano),
+			"type": "platform.initReport",
+			"record": map[string]interface{}{
+				"initializationType": initializationType,
+				"phase":              "init",
+				"status":             defaultStatus,
+				"metrics":            map[string]interface{}{"durationMs": durationMs},
+			},
+		},
+	}
+}
+
+func runtimeDoneEvent(invocation *Invocation, start time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"time": start.Add(invocation.Duration).Format(time.RFC3339Nano),
+		"type": "platform.runtimeDone",
+		"record": map[string]interface{}{
+			"requestId": invocation.RequestId,
+			"status":    invocation.Status,
+			"metrics":   map[string]interface{}{"durationMs": float64(invocation.Duration) / float64(time.Millisecond)},
+		},
+	}
+}
+
+func reportEvent(invocation *Invocation, start time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"time": start.Add(invocation.Duration).Format(time.RFC3339Nano),
+		"type": "platform.report",
+		"record": map[string]interface{}{
+			"requestId": invocation.RequestId,
+			"status":    invocation.Status,
+			"metrics": map[string]interface{}{
+				"durationMs":       float64(invocation.Duration) / float64(time.Millisecond),
+				"billedDurationMs": float64(invocation.Duration.Milliseconds() + 1),
+				"memorySizeMB":     memorySizeMB,
+				"maxMemoryUsedMB":  maxMemoryUsedMB,
+			},
+		},
+	}
+}
+
+func shutdownEvent(reason string) map[string]interface{} {
+	return map[string]interface{}{
+		"eventType":      "SHUTDOWN",
+		"shutdownReason": reason,
+		"deadlineMs":     time.Now().Add(2*time.Second).UnixNano() / int64(time.Millisecond),
+	}
+}
+
+func writeJson(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,51 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/splunk/lambda-extension/internal/config"
+	"log"
+)
+
+// New creates the sink sending the datapoints to every configured destination.
+func New(configuration *config.Configuration) sfxclient.Sink {
+	destinations := make([]destination, 0, len(configuration.Destinations))
+	for _, d := range configuration.Destinations {
+		sink := newSink(d)
+		if configuration.SpoolEnabled {
+			sink = newSpoolingSink(sink, d.Name, configuration.SpoolDir, configuration.SpoolMaxBytes, configuration.SpoolMaxAge)
+		} else {
+			// without the spool, only the batches cut short by the deadline of an invocation are kept, in memory
+			sink = newRetainingSink(sink, d.Name)
+		}
+		destinations = append(destinations, destination{Destination: d, sink: sink})
+	}
+	return &fanOutSink{destinations: destinations}
+}
+
+func newSink(d config.Destination) sfxclient.Sink {
+	switch d.Exporter {
+	case config.ExporterOTLP:
+		return newOtlpSink(d.Url, d.Token, d.Timeout)
+	case config.ExporterPrometheus:
+		return newPrometheusSink(d.Url, d.Token, d.Timeout)
+	case config.ExporterSignalFx:
+		return newSignalFxSink(d.Url, d.Token, d.Timeout)
+	default:
+		log.Printf("unknown metrics exporter of %v: %v, using %v\n", d.Name, d.Exporter, config.ExporterSignalFx)
+		return newSignalFxSink(d.Url, d.Token, d.Timeout)
+	}
+}
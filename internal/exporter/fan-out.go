@@ -0,0 +1,110 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/splunk/lambda-extension/internal/config"
+	"strings"
+	"sync"
+)
+
+type destination struct {
+	config.Destination
+	sink sfxclient.Sink
+}
+
+// fanOutSink sends the same datapoints to all the destinations concurrently,
+// each one bounded by its own timeout, so a slow or failing destination doesn't affect the others.
+type fanOutSink struct {
+	destinations []destination
+}
+
+type DestinationError struct {
+	Name     string
+	FailFast bool
+	Err      error
+}
+
+// Errors lists the destinations which failed to receive the datapoints.
+type Errors []DestinationError
+
+func (errs Errors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, fmt.Sprintf("%v: %v", err.Name, err.Err))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// FailFast tells whether any of the failed destinations is configured to stop the extension.
+func (errs Errors) FailFast() bool {
+	for _, err := range errs {
+		if err.FailFast {
+			return true
+		}
+	}
+	return false
+}
+
+func (sink *fanOutSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	results := make([]error, len(sink.destinations))
+
+	var wg sync.WaitGroup
+	for i, d := range sink.destinations {
+		wg.Add(1)
+		go func(i int, d destination) {
+			defer wg.Done()
+
+			// every destination gets its own context, the timeouts must not add up
+			destinationCtx := ctx
+			if d.Timeout > 0 {
+				var cancel context.CancelFunc
+				destinationCtx, cancel = context.WithTimeout(ctx, d.Timeout)
+				defer cancel()
+			}
+
+			results[i] = d.sink.AddDatapoints(destinationCtx, points)
+		}(i, d)
+	}
+	wg.Wait()
+
+	var errs Errors
+	for i, err := range results {
+		if err != nil {
+			d := sink.destinations[i]
+			errs = append(errs, DestinationError{Name: d.Name, FailFast: d.FailFast, Err: err})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Datapoints reports the metrics of the destinations themselves, e.g. of their spools
+func (sink *fanOutSink) Datapoints() []*datapoint.Datapoint {
+	var dps []*datapoint.Datapoint
+	for _, d := range sink.destinations {
+		if collector, ok := d.sink.(sfxclient.Collector); ok {
+			dps = append(dps, collector.Datapoints()...)
+		}
+	}
+	return dps
+}
@@ -0,0 +1,70 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/splunk/lambda-extension/internal/config"
+	"testing"
+	"time"
+)
+
+type stubSink struct {
+	received []*datapoint.Datapoint
+	err      error
+	delay    time.Duration
+}
+
+func (ss *stubSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	select {
+	case <-time.After(ss.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	ss.received = points
+	return ss.err
+}
+
+func TestFanOutFailuresAreIndependent(t *testing.T) {
+	healthy := &stubSink{}
+	failing := &stubSink{err: errors.New("forbidden")}
+	slow := &stubSink{delay: time.Minute}
+
+	sink := &fanOutSink{destinations: []destination{
+		{Destination: config.Destination{Name: "healthy", Timeout: time.Second}, sink: healthy},
+		{Destination: config.Destination{Name: "failing", Timeout: time.Second}, sink: failing},
+		{Destination: config.Destination{Name: "slow", Timeout: 10 * time.Millisecond, FailFast: true}, sink: slow},
+	}}
+
+	err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{sfxclient.Gauge("x", nil, 1)})
+
+	if len(healthy.received) != 1 {
+		t.Errorf("Expected the healthy destination to receive the datapoint")
+	}
+
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Expected errors of 2 destinations, got %v", err)
+	}
+	if errs[0].Name != "failing" || errs[1].Name != "slow" {
+		t.Errorf("Unexpected failed destinations: %v", errs)
+	}
+	if !errs.FailFast() {
+		t.Errorf("Expected the slow destination to fail fast")
+	}
+}
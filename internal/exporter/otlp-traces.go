@@ -0,0 +1,208 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/signalfx/golib/v3/trace"
+	"google.golang.org/protobuf/encoding/protowire"
+	"log"
+	"net/http"
+	"time"
+)
+
+// field numbers of the OTLP traces protocol (opentelemetry/proto/trace/v1/trace.proto)
+const (
+	exportRequestResourceSpans protowire.Number = 1
+
+	resourceSpansResource   protowire.Number = 1
+	resourceSpansScopeSpans protowire.Number = 2
+
+	scopeSpansScope protowire.Number = 1
+	scopeSpansSpans protowire.Number = 2
+
+	spanTraceId    protowire.Number = 1
+	spanId         protowire.Number = 2
+	spanParentId   protowire.Number = 4
+	spanName       protowire.Number = 5
+	spanKind       protowire.Number = 6
+	spanStartTime  protowire.Number = 7
+	spanEndTime    protowire.Number = 8
+	spanAttributes protowire.Number = 9
+	spanStatus     protowire.Number = 15
+
+	statusCode      protowire.Number = 3
+	statusCodeError                  = 2
+)
+
+const serviceNameAttribute = "service.name"
+
+// the span kinds of Zipkin mapped to the enum of OTLP
+var spanKinds = map[string]uint64{
+	"SERVER":   2,
+	"CLIENT":   3,
+	"PRODUCER": 4,
+	"CONSUMER": 5,
+}
+
+type otlpTraceSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type spanGroup struct {
+	attributes map[string]string
+	spans      []otlpSpan
+}
+
+type otlpSpan struct {
+	*trace.Span
+	attributes map[string]string
+}
+
+func newOtlpTraceSink(url, token string, timeout time.Duration) *otlpTraceSink {
+	return &otlpTraceSink{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (sink *otlpTraceSink) AddSpans(ctx context.Context, spans []*trace.Span) error {
+	if len(spans) == 0 || sink.url == "" {
+		return nil
+	}
+
+	body := encodeTraceExportRequest(groupSpansByResource(spans))
+	if len(body) == 0 {
+		return nil
+	}
+	return postProtobuf(ctx, sink.client, sink.url, sink.token, body)
+}
+
+// groupSpansByResource moves the tags describing the function to the resource, like the dimensions of the datapoints
+func groupSpansByResource(spans []*trace.Span) []*spanGroup {
+	groups := map[string]*spanGroup{}
+	var ordered []*spanGroup
+
+	for _, span := range spans {
+		resource, attributes := splitAttributes(span.Tags)
+		if span.LocalEndpoint != nil && span.LocalEndpoint.ServiceName != nil {
+			resource[serviceNameAttribute] = *span.LocalEndpoint.ServiceName
+		}
+
+		key := attributesKey(resource)
+		group, found := groups[key]
+		if !found {
+			group = &spanGroup{attributes: resource}
+			groups[key] = group
+			ordered = append(ordered, group)
+		}
+		group.spans = append(group.spans, otlpSpan{Span: span, attributes: attributes})
+	}
+
+	return ordered
+}
+
+func encodeTraceExportRequest(groups []*spanGroup) []byte {
+	var request []byte
+
+	for _, group := range groups {
+		var resource []byte
+		for _, k := range sortedKeys(group.attributes) {
+			resource = appendMessage(resource, resourceAttributesField, encodeKeyValue(k, group.attributes[k]))
+		}
+
+		scopeSpans := appendMessage(nil, scopeSpansScope, appendString(nil, scopeNameField, scopeName))
+		encodedSpans := 0
+		for _, span := range group.spans {
+			if encoded, ok := encodeSpan(span); ok {
+				scopeSpans = appendMessage(scopeSpans, scopeSpansSpans, encoded)
+				encodedSpans++
+			}
+		}
+		if encodedSpans == 0 {
+			continue
+		}
+
+		resourceSpans := appendMessage(nil, resourceSpansResource, resource)
+		resourceSpans = appendMessage(resourceSpans, resourceSpansScopeSpans, scopeSpans)
+
+		request = appendMessage(request, exportRequestResourceSpans, resourceSpans)
+	}
+
+	return request
+}
+
+// encodeSpan returns false for the spans without valid hex IDs or without a timestamp
+func encodeSpan(span otlpSpan) ([]byte, bool) {
+	traceId, err := hex.DecodeString(span.TraceID)
+	if err != nil || len(traceId) != 16 {
+		log.Printf("can't export span with trace ID: %v\n", span.TraceID)
+		return nil, false
+	}
+	id, err := hex.DecodeString(span.ID)
+	if err != nil || len(id) != 8 {
+		log.Printf("can't export span with ID: %v\n", span.ID)
+		return nil, false
+	}
+	if span.Timestamp == nil {
+		return nil, false
+	}
+
+	encoded := protowire.AppendTag(nil, spanTraceId, protowire.BytesType)
+	encoded = protowire.AppendBytes(encoded, traceId)
+	encoded = protowire.AppendTag(encoded, spanId, protowire.BytesType)
+	encoded = protowire.AppendBytes(encoded, id)
+
+	if span.ParentID != nil {
+		if parentId, err := hex.DecodeString(*span.ParentID); err == nil {
+			encoded = protowire.AppendTag(encoded, spanParentId, protowire.BytesType)
+			encoded = protowire.AppendBytes(encoded, parentId)
+		}
+	}
+	if span.Name != nil {
+		encoded = appendString(encoded, spanName, *span.Name)
+	}
+	if span.Kind != nil {
+		if kind, found := spanKinds[*span.Kind]; found {
+			encoded = protowire.AppendTag(encoded, spanKind, protowire.VarintType)
+			encoded = protowire.AppendVarint(encoded, kind)
+		}
+	}
+
+	start := time.Unix(0, *span.Timestamp*int64(time.Microsecond))
+	end := start
+	if span.Duration != nil {
+		end = start.Add(time.Duration(*span.Duration) * time.Microsecond)
+	}
+	encoded = appendTimestamp(encoded, spanStartTime, start)
+	encoded = appendTimestamp(encoded, spanEndTime, end)
+
+	for _, k := range sortedKeys(span.attributes) {
+		encoded = appendMessage(encoded, spanAttributes, encodeKeyValue(k, span.attributes[k]))
+	}
+
+	// Zipkin marks the failed spans with the error tag
+	if _, failed := span.Tags["error"]; failed {
+		status := protowire.AppendTag(nil, statusCode, protowire.VarintType)
+		status = protowire.AppendVarint(status, statusCodeError)
+		encoded = appendMessage(encoded, spanStatus, status)
+	}
+
+	return encoded, true
+}
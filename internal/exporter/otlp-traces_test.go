@@ -0,0 +1,112 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/signalfx/golib/v3/trace"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOtlpTraceSink(t *testing.T) {
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	sink := newOtlpTraceSink(server.URL, "token", time.Second)
+
+	name, kind, service := "helloworld", "SERVER", "helloworld"
+	timestamp, duration := time.Now().UnixNano()/int64(time.Microsecond), int64(1500)
+	span := &trace.Span{
+		TraceID:       "0af7651916cd43dd8448eb211c80319c",
+		ID:            "b7ad6b7169203331",
+		Name:          &name,
+		Kind:          &kind,
+		Timestamp:     &timestamp,
+		Duration:      &duration,
+		LocalEndpoint: &trace.Endpoint{ServiceName: &service},
+		Tags:          map[string]string{"aws_region": "us-east-1", "aws_request_id": "8476a536", "error": "true"},
+	}
+	invalid := &trace.Span{TraceID: "not hex", ID: "b7ad6b7169203331", Timestamp: &timestamp}
+
+	if err := sink.AddSpans(context.Background(), []*trace.Span{span, invalid}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the field numbers are taken from opentelemetry/proto/trace/v1/trace.proto, independently of the exporter
+	resourceSpans := decodeMessage(t, body).message(t, 1) // ExportTraceServiceRequest.resource_spans
+
+	resource := resourceSpans.message(t, 1).attributes(t, 1) // ResourceSpans.resource, Resource.attributes
+	if resource[serviceNameAttribute] != "helloworld" || resource["cloud.region"] != "us-east-1" || len(resource) != 4 {
+		t.Errorf("Unexpected resource attributes: %v", resource)
+	}
+
+	scopeSpans := resourceSpans.message(t, 2) // ResourceSpans.scope_spans
+	// ScopeSpans.scope, InstrumentationScope.name
+	if name := scopeSpans.message(t, 1).string(1); name != scopeName {
+		t.Errorf("Expected `%v`, got `%v`", scopeName, name)
+	}
+
+	// ScopeSpans.spans, the span with an invalid ID is skipped
+	encoded := scopeSpans.message(t, 2)
+
+	// Span.trace_id
+	if traceId := hex.EncodeToString(encoded.bytes(1)); traceId != span.TraceID {
+		t.Errorf("Expected `%v`, got `%v`", span.TraceID, traceId)
+	}
+	// Span.span_id
+	if id := hex.EncodeToString(encoded.bytes(2)); id != span.ID {
+		t.Errorf("Expected `%v`, got `%v`", span.ID, id)
+	}
+	// Span.parent_span_id
+	if _, found := encoded[4]; found {
+		t.Errorf("Expected no parent of a root span")
+	}
+	// Span.name
+	if encodedName := encoded.string(5); encodedName != name {
+		t.Errorf("Expected `%v`, got `%v`", name, encodedName)
+	}
+	// Span.kind, SPAN_KIND_SERVER
+	if encodedKind := encoded.number(6); encodedKind != 2 {
+		t.Errorf("Expected `%v`, got `%v`", 2, encodedKind)
+	}
+
+	start := uint64(timestamp * int64(time.Microsecond))
+	// Span.start_time_unix_nano
+	if encodedStart := encoded.number(7); encodedStart != start {
+		t.Errorf("Expected `%v`, got `%v`", start, encodedStart)
+	}
+	// Span.end_time_unix_nano
+	if end := encoded.number(8); end != start+uint64(1500*time.Microsecond) {
+		t.Errorf("Expected `%v`, got `%v`", start+uint64(1500*time.Microsecond), end)
+	}
+
+	// Span.attributes
+	if attributes := encoded.attributes(t, 9); len(attributes) != 2 || attributes["aws_request_id"] != "8476a536" || attributes["error"] != "true" {
+		t.Errorf("Unexpected span attributes: %v", attributes)
+	}
+	// Span.status, Status.code, STATUS_CODE_ERROR
+	if code := encoded.message(t, 15).number(3); code != 2 {
+		t.Errorf("Expected `%v`, got `%v`", 2, code)
+	}
+}
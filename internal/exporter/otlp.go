@@ -0,0 +1,312 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const scopeName = "splunk-extension-wrapper"
+
+// the dimensions describing the function are mapped to resource attributes
+// following the OpenTelemetry FaaS semantic conventions, see dimensions.go in the metrics package
+var resourceAttributes = map[string]string{
+	"aws_function_name":    "faas.name",
+	"aws_function_version": "faas.version",
+	"aws_region":           "cloud.region",
+	"aws_account_id":       "cloud.account.id",
+	"aws_arn":              "cloud.resource_id",
+}
+
+var constantResourceAttributes = map[string]string{
+	"cloud.provider": "aws",
+	"cloud.platform": "aws_lambda",
+}
+
+// field numbers of the OTLP metrics protocol (opentelemetry/proto/metrics/v1/metrics.proto)
+const (
+	exportRequestResourceMetrics protowire.Number = 1
+
+	resourceMetricsResource     protowire.Number = 1
+	resourceMetricsScopeMetrics protowire.Number = 2
+	resourceAttributesField     protowire.Number = 1
+
+	scopeMetricsScope   protowire.Number = 1
+	scopeMetricsMetrics protowire.Number = 2
+	scopeNameField      protowire.Number = 1
+
+	metricName  protowire.Number = 1
+	metricGauge protowire.Number = 5
+	metricSum   protowire.Number = 7
+
+	gaugeDataPoints       protowire.Number = 1
+	sumDataPoints         protowire.Number = 1
+	sumTemporality        protowire.Number = 2
+	sumMonotonic          protowire.Number = 3
+	temporalityDelta                       = 1
+	temporalityCumulative                  = 2
+
+	numberStartTime  protowire.Number = 2
+	numberTime       protowire.Number = 3
+	numberAsDouble   protowire.Number = 4
+	numberAsInt      protowire.Number = 6
+	numberAttributes protowire.Number = 7
+
+	keyValueKey    protowire.Number = 1
+	keyValueValue  protowire.Number = 2
+	anyValueString protowire.Number = 1
+)
+
+type otlpSink struct {
+	url    string
+	token  string
+	client *http.Client
+
+	// cumulative counters start with the process, delta counters with the previous export
+	processStart time.Time
+	mu           sync.Mutex
+	lastExport   time.Time
+}
+
+type otlpPoint struct {
+	*datapoint.Datapoint
+	attributes map[string]string
+}
+
+type resourceGroup struct {
+	attributes map[string]string
+	points     []otlpPoint
+}
+
+func newOtlpSink(url, token string, timeout time.Duration) *otlpSink {
+	return &otlpSink{
+		url:          url,
+		token:        token,
+		client:       &http.Client{Timeout: timeout},
+		processStart: time.Now(),
+	}
+}
+
+func (sink *otlpSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	body := sink.encodeRequest(points)
+	if body == nil {
+		return nil
+	}
+	return sink.sendRequest(ctx, body)
+}
+
+// encodeRequest starts the next delta interval, a failed request is resent as encoded by the spool, with its own interval
+func (sink *otlpSink) encodeRequest(points []*datapoint.Datapoint) []byte {
+	if len(points) == 0 || sink.url == "" {
+		return nil
+	}
+
+	sink.mu.Lock()
+	intervalStart := sink.lastExport
+	sink.lastExport = time.Now()
+	sink.mu.Unlock()
+
+	if intervalStart.IsZero() {
+		intervalStart = sink.processStart
+	}
+
+	return encodeExportRequest(groupByResource(points), sink.processStart, intervalStart)
+}
+
+func (sink *otlpSink) sendRequest(ctx context.Context, body []byte) error {
+	return postProtobuf(ctx, sink.client, sink.url, sink.token, body)
+}
+
+func postProtobuf(ctx context.Context, client *http.Client, url, token string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("can't create http request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if token != "" {
+		req.Header.Set("X-SF-Token", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP endpoint returned: %v %s", resp.Status, respBody)
+	}
+
+	return nil
+}
+
+func groupByResource(points []*datapoint.Datapoint) []*resourceGroup {
+	groups := map[string]*resourceGroup{}
+	var ordered []*resourceGroup
+
+	for _, dp := range points {
+		resource, attributes := splitAttributes(dp.Dimensions)
+
+		key := attributesKey(resource)
+		group, found := groups[key]
+		if !found {
+			group = &resourceGroup{attributes: resource}
+			groups[key] = group
+			ordered = append(ordered, group)
+		}
+		group.points = append(group.points, otlpPoint{Datapoint: dp, attributes: attributes})
+	}
+
+	return ordered
+}
+
+// splitAttributes separates the dimensions describing the function from the rest
+func splitAttributes(dims map[string]string) (resource map[string]string, attributes map[string]string) {
+	resource = map[string]string{}
+	for k, v := range constantResourceAttributes {
+		resource[k] = v
+	}
+	attributes = map[string]string{}
+
+	for k, v := range dims {
+		if attribute, found := resourceAttributes[k]; found {
+			resource[attribute] = v
+		} else {
+			attributes[k] = v
+		}
+	}
+	return resource, attributes
+}
+
+func attributesKey(attributes map[string]string) string {
+	builder := strings.Builder{}
+	for _, k := range sortedKeys(attributes) {
+		builder.WriteString(k + "=" + attributes[k] + ";")
+	}
+	return builder.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func encodeExportRequest(groups []*resourceGroup, processStart, intervalStart time.Time) []byte {
+	var request []byte
+
+	for _, group := range groups {
+		var resource []byte
+		for _, k := range sortedKeys(group.attributes) {
+			resource = appendMessage(resource, resourceAttributesField, encodeKeyValue(k, group.attributes[k]))
+		}
+
+		scopeMetrics := appendMessage(nil, scopeMetricsScope, appendString(nil, scopeNameField, scopeName))
+		for _, point := range group.points {
+			if metric, ok := encodeMetric(point, processStart, intervalStart); ok {
+				scopeMetrics = appendMessage(scopeMetrics, scopeMetricsMetrics, metric)
+			}
+		}
+
+		resourceMetrics := appendMessage(nil, resourceMetricsResource, resource)
+		resourceMetrics = appendMessage(resourceMetrics, resourceMetricsScopeMetrics, scopeMetrics)
+
+		request = appendMessage(request, exportRequestResourceMetrics, resourceMetrics)
+	}
+
+	return request
+}
+
+func encodeMetric(point otlpPoint, processStart, intervalStart time.Time) ([]byte, bool) {
+	metric := appendString(nil, metricName, point.Metric)
+
+	switch point.MetricType {
+	case datapoint.Count:
+		dataPoint, ok := encodeNumberDataPoint(point, intervalStart)
+		if !ok {
+			return nil, false
+		}
+		return appendMessage(metric, metricSum, encodeSum(dataPoint, temporalityDelta)), true
+	case datapoint.Counter:
+		dataPoint, ok := encodeNumberDataPoint(point, processStart)
+		if !ok {
+			return nil, false
+		}
+		return appendMessage(metric, metricSum, encodeSum(dataPoint, temporalityCumulative)), true
+	default:
+		dataPoint, ok := encodeNumberDataPoint(point, time.Time{})
+		if !ok {
+			return nil, false
+		}
+		return appendMessage(metric, metricGauge, appendMessage(nil, gaugeDataPoints, dataPoint)), true
+	}
+}
+
+func encodeSum(dataPoint []byte, temporality uint64) []byte {
+	sum := appendMessage(nil, sumDataPoints, dataPoint)
+	sum = protowire.AppendTag(sum, sumTemporality, protowire.VarintType)
+	sum = protowire.AppendVarint(sum, temporality)
+	sum = protowire.AppendTag(sum, sumMonotonic, protowire.VarintType)
+	return protowire.AppendVarint(sum, 1)
+}
+
+// encodeNumberDataPoint returns false for the values which can't be expressed as a number
+func encodeNumberDataPoint(point otlpPoint, start time.Time) ([]byte, bool) {
+	var dataPoint []byte
+
+	for _, k := range sortedKeys(point.attributes) {
+		dataPoint = appendMessage(dataPoint, numberAttributes, encodeKeyValue(k, point.attributes[k]))
+	}
+
+	if !start.IsZero() {
+		dataPoint = appendTimestamp(dataPoint, numberStartTime, start)
+	}
+	dataPoint = appendTimestamp(dataPoint, numberTime, point.Timestamp)
+
+	switch value := point.Value.(type) {
+	case datapoint.IntValue:
+		dataPoint = protowire.AppendTag(dataPoint, numberAsInt, protowire.Fixed64Type)
+		dataPoint = protowire.AppendFixed64(dataPoint, uint64(value.Int()))
+	case datapoint.FloatValue:
+		dataPoint = protowire.AppendTag(dataPoint, numberAsDouble, protowire.Fixed64Type)
+		dataPoint = protowire.AppendFixed64(dataPoint, math.Float64bits(value.Float()))
+	default:
+		return nil, false
+	}
+
+	return dataPoint, true
+}
+
+func encodeKeyValue(key, value string) []byte {
+	keyValue := appendString(nil, keyValueKey, key)
+	return appendMessage(keyValue, keyValueValue, appendString(nil, anyValueString, value))
+}
@@ -0,0 +1,142 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGroupByResource(t *testing.T) {
+	dims := func(version, qualifier string) map[string]string {
+		return map[string]string{
+			"aws_function_name":      "helloworld",
+			"aws_function_version":   version,
+			"aws_function_qualifier": qualifier,
+		}
+	}
+
+	groups := groupByResource([]*datapoint.Datapoint{
+		sfxclient.Counter("lambda.function.invocation", dims("1", "live"), 1),
+		sfxclient.Counter("lambda.function.invocation", dims("1", "test"), 1),
+		sfxclient.Counter("lambda.function.invocation", dims("2", "live"), 1),
+	})
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 resources, got %v", len(groups))
+	}
+
+	first := groups[0]
+	if first.attributes["faas.name"] != "helloworld" || first.attributes["faas.version"] != "1" || first.attributes["cloud.provider"] != "aws" {
+		t.Errorf("Unexpected resource attributes: %v", first.attributes)
+	}
+	if len(first.points) != 2 || first.points[1].attributes["aws_function_qualifier"] != "test" {
+		t.Errorf("Expected the qualifier to stay a datapoint attribute, got %v", first.points)
+	}
+}
+
+func TestOtlpSink(t *testing.T) {
+	var body []byte
+	var contentType, token string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		token = r.Header.Get("X-SF-Token")
+	}))
+	defer server.Close()
+
+	sink := newOtlpSink(server.URL, "token", time.Second)
+
+	now := time.Now()
+	dims := map[string]string{"aws_region": "us-east-1", "aws_function_qualifier": "live"}
+	gauge := sfxclient.GaugeF("lambda.function.duration", dims, 12.5)
+	gauge.Timestamp = now
+	count := datapoint.New("lambda.function.invocation", dims, datapoint.NewIntValue(3), datapoint.Count, now)
+
+	if err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{gauge, count}); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "application/x-protobuf" || token != "token" {
+		t.Errorf("Unexpected headers: %v, %v", contentType, token)
+	}
+
+	// the field numbers are taken from opentelemetry/proto/metrics/v1/metrics.proto, independently of the exporter
+	resourceMetrics := decodeMessage(t, body).message(t, 1) // ExportMetricsServiceRequest.resource_metrics
+
+	resource := resourceMetrics.message(t, 1).attributes(t, 1) // ResourceMetrics.resource, Resource.attributes
+	if resource["cloud.region"] != "us-east-1" || resource["cloud.provider"] != "aws" || len(resource) != 3 {
+		t.Errorf("Unexpected resource attributes: %v", resource)
+	}
+
+	scopeMetrics := resourceMetrics.message(t, 2) // ResourceMetrics.scope_metrics
+	// ScopeMetrics.scope, InstrumentationScope.name
+	if name := scopeMetrics.message(t, 1).string(1); name != scopeName {
+		t.Errorf("Expected `%v`, got `%v`", scopeName, name)
+	}
+
+	metrics := scopeMetrics.messages(t, 2) // ScopeMetrics.metrics
+	if len(metrics) != 2 {
+		t.Fatalf("Expected `%v` metrics, got `%v`", 2, len(metrics))
+	}
+
+	// Metric.name
+	if name := metrics[0].string(1); name != "lambda.function.duration" {
+		t.Errorf("Expected `%v`, got `%v`", "lambda.function.duration", name)
+	}
+	gaugePoint := metrics[0].message(t, 5).message(t, 1) // Metric.gauge, Gauge.data_points
+	// NumberDataPoint.as_double
+	if value := math.Float64frombits(gaugePoint.number(4)); value != 12.5 {
+		t.Errorf("Expected `%v`, got `%v`", 12.5, value)
+	}
+	// NumberDataPoint.time_unix_nano
+	if timestamp := gaugePoint.number(3); timestamp != uint64(now.UnixNano()) {
+		t.Errorf("Expected `%v`, got `%v`", now.UnixNano(), timestamp)
+	}
+	// NumberDataPoint.start_time_unix_nano
+	if _, found := gaugePoint[2]; found {
+		t.Errorf("Expected no start time of a gauge")
+	}
+	// NumberDataPoint.attributes
+	if attributes := gaugePoint.attributes(t, 7); len(attributes) != 1 || attributes["aws_function_qualifier"] != "live" {
+		t.Errorf("Unexpected datapoint attributes: %v", attributes)
+	}
+
+	sum := metrics[1].message(t, 7) // Metric.sum
+	// Sum.aggregation_temporality, AGGREGATION_TEMPORALITY_DELTA
+	if temporality := sum.number(2); temporality != 1 {
+		t.Errorf("Expected `%v`, got `%v`", 1, temporality)
+	}
+	// Sum.is_monotonic
+	if monotonic := sum.number(3); monotonic != 1 {
+		t.Errorf("Expected a monotonic sum, got `%v`", monotonic)
+	}
+	sumPoint := sum.message(t, 1) // Sum.data_points
+	// NumberDataPoint.as_int
+	if value := sumPoint.number(6); value != 3 {
+		t.Errorf("Expected `%v`, got `%v`", 3, value)
+	}
+	if start := sumPoint.number(2); start != uint64(sink.processStart.UnixNano()) {
+		t.Errorf("Expected the first interval to start with the process, got `%v`", start)
+	}
+}
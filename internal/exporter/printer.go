@@ -0,0 +1,87 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/signalfx/golib/v3/trace"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type printingSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewPrinter creates a sink writing the datapoints in a human readable form instead of sending them,
+// every batch starts with a header line, so the reporting cadence can be seen.
+func NewPrinter(out io.Writer) sfxclient.Sink {
+	return &printingSink{out: out}
+}
+
+func (s *printingSink) AddDatapoints(_ context.Context, points []*datapoint.Datapoint) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %v, %d datapoints\n", time.Now().Format(time.RFC3339Nano), len(points))
+	for _, dp := range points {
+		fmt.Fprintf(&b, "%v %v %v %v\n", dp.Metric, dp.MetricType, dp.Value, formatDimensions(dp.Dimensions))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := io.WriteString(s.out, b.String())
+	return err
+}
+
+// AddSpans makes the printer a trace.Sink too, a span is printed with its duration in microseconds
+func (s *printingSink) AddSpans(_ context.Context, spans []*trace.Span) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "--- %v, %d spans\n", time.Now().Format(time.RFC3339Nano), len(spans))
+	for _, span := range spans {
+		var name string
+		var duration int64
+		if span.Name != nil {
+			name = *span.Name
+		}
+		if span.Duration != nil {
+			duration = *span.Duration
+		}
+		fmt.Fprintf(&b, "span %v %v/%v %vus %v\n", name, span.TraceID, span.ID, duration, formatDimensions(span.Tags))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := io.WriteString(s.out, b.String())
+	return err
+}
+
+func formatDimensions(dims map[string]string) string {
+	pairs := make([]string, 0, len(dims))
+	for k, v := range dims {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
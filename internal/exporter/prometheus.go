@@ -0,0 +1,208 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/signalfx/golib/v3/datapoint"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const counterSuffix = "_total"
+
+// field numbers of the Prometheus remote write protocol (prometheus/prompb/remote.proto and types.proto)
+const (
+	writeRequestTimeseries protowire.Number = 1
+
+	timeSeriesLabels  protowire.Number = 1
+	timeSeriesSamples protowire.Number = 2
+
+	labelName  protowire.Number = 1
+	labelValue protowire.Number = 2
+
+	sampleValue     protowire.Number = 1
+	sampleTimestamp protowire.Number = 2
+)
+
+type prometheusSink struct {
+	url    string
+	token  string
+	client *http.Client
+
+	// Prometheus counters are cumulative, the SignalFx delta counters are summed up here
+	mu     sync.Mutex
+	totals map[string]float64
+}
+
+type label struct {
+	name, value string
+}
+
+func newPrometheusSink(url, token string, timeout time.Duration) *prometheusSink {
+	return &prometheusSink{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: timeout},
+		totals: make(map[string]float64),
+	}
+}
+
+func (sink *prometheusSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	body := sink.encodeRequest(points)
+	if body == nil {
+		return nil
+	}
+	return sink.sendRequest(ctx, body)
+}
+
+// encodeRequest adds the counters to the totals, a failed request is resent as encoded by the spool,
+// so its values are counted only once
+func (sink *prometheusSink) encodeRequest(points []*datapoint.Datapoint) []byte {
+	if len(points) == 0 || sink.url == "" {
+		return nil
+	}
+	return snappy.Encode(nil, sink.encodeWriteRequest(points))
+}
+
+func (sink *prometheusSink) sendRequest(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("can't create http request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if sink.token != "" {
+		req.Header.Set("Authorization", "Bearer "+sink.token)
+	}
+
+	resp, err := sink.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("remote write endpoint returned: %v %s", resp.Status, respBody)
+	}
+
+	return nil
+}
+
+func (sink *prometheusSink) encodeWriteRequest(points []*datapoint.Datapoint) []byte {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	var request []byte
+
+	for _, dp := range points {
+		value, ok := floatValue(dp.Value)
+		if !ok {
+			continue
+		}
+
+		name := sanitizeName(dp.Metric, true)
+		switch dp.MetricType {
+		case datapoint.Count:
+			name += counterSuffix
+			labels := prometheusLabels(name, dp.Dimensions)
+			key := labelsKey(labels)
+			sink.totals[key] += value
+			value = sink.totals[key]
+			request = appendMessage(request, writeRequestTimeseries, encodeTimeSeries(labels, value, dp.Timestamp))
+		case datapoint.Counter:
+			name += counterSuffix
+			fallthrough
+		default:
+			request = appendMessage(request, writeRequestTimeseries, encodeTimeSeries(prometheusLabels(name, dp.Dimensions), value, dp.Timestamp))
+		}
+	}
+
+	return request
+}
+
+func floatValue(value datapoint.Value) (float64, bool) {
+	switch v := value.(type) {
+	case datapoint.IntValue:
+		return float64(v.Int()), true
+	case datapoint.FloatValue:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// prometheusLabels returns the labels sorted by name, as required by the remote write protocol
+func prometheusLabels(name string, dims map[string]string) []label {
+	labels := []label{{name: "__name__", value: name}}
+	for k, v := range dims {
+		labels = append(labels, label{name: sanitizeName(k, false), value: v})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+func labelsKey(labels []label) string {
+	builder := strings.Builder{}
+	for _, l := range labels {
+		builder.WriteString(l.name + "=" + l.value + ";")
+	}
+	return builder.String()
+}
+
+func encodeTimeSeries(labels []label, value float64, timestamp time.Time) []byte {
+	var timeSeries []byte
+	for _, l := range labels {
+		encodedLabel := appendString(nil, labelName, l.name)
+		encodedLabel = appendString(encodedLabel, labelValue, l.value)
+		timeSeries = appendMessage(timeSeries, timeSeriesLabels, encodedLabel)
+	}
+
+	sample := protowire.AppendTag(nil, sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(timestamp.UnixNano()/int64(time.Millisecond)))
+
+	return appendMessage(timeSeries, timeSeriesSamples, sample)
+}
+
+// sanitizeName replaces the characters not allowed in metric names ([a-zA-Z_:][a-zA-Z0-9_:]*)
+// or label names (the same without colons) with underscores, names can't start with a digit
+func sanitizeName(name string, metric bool) string {
+	sanitized := []rune(name)
+	for i, r := range sanitized {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || (metric && r == ':')
+		if !valid {
+			sanitized[i] = '_'
+		}
+	}
+	if len(sanitized) == 0 || (sanitized[0] >= '0' && sanitized[0] <= '9') {
+		return "_" + string(sanitized)
+	}
+	return string(sanitized)
+}
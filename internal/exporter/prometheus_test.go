@@ -0,0 +1,84 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"github.com/golang/snappy"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSanitizeName(t *testing.T) {
+	cases := map[string]string{
+		"lambda.function.invocation": "lambda_function_invocation",
+		"ns:metric":                  "ns:metric",
+		"9lives":                     "_9lives",
+	}
+	for name, expected := range cases {
+		if actual := sanitizeName(name, true); actual != expected {
+			t.Errorf("Expected `%v`, got `%v`", expected, actual)
+		}
+	}
+
+	if actual := sanitizeName("aws:region", false); actual != "aws_region" {
+		t.Errorf("Expected colons to be replaced in label names, got `%v`", actual)
+	}
+}
+
+func TestPrometheusSinkAccumulatesCounters(t *testing.T) {
+	var bodies [][]byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := ioutil.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil || r.Header.Get("Content-Encoding") != "snappy" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	sink := newPrometheusSink(server.URL, "", time.Second)
+
+	for i := 0; i < 2; i++ {
+		dp := sfxclient.Counter("lambda.function.invocation", map[string]string{"aws_region": "us-east-1"}, 3)
+		dp.Timestamp = time.Now()
+		if err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{dp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("Expected 2 requests, got %v", len(bodies))
+	}
+
+	for _, expected := range []string{"lambda_function_invocation_total", "aws_region", "__name__"} {
+		if !bytes.Contains(bodies[1], []byte(expected)) {
+			t.Errorf("Expected `%v` in the request", expected)
+		}
+	}
+
+	if total := sink.totals[labelsKey(prometheusLabels("lambda_function_invocation_total", map[string]string{"aws_region": "us-east-1"}))]; total != 6 {
+		t.Errorf("Expected the counter to be cumulative, got %v", total)
+	}
+}
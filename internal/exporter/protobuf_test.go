@@ -0,0 +1,113 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+)
+
+// protoMessage is a decoded protobuf message, good enough to check the hand encoded requests
+type protoMessage map[protowire.Number][]protoValue
+
+type protoValue struct {
+	number uint64
+	bytes  []byte
+}
+
+func decodeMessage(t *testing.T, b []byte) protoMessage {
+	t.Helper()
+
+	message := protoMessage{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var value protoValue
+		switch typ {
+		case protowire.VarintType:
+			value.number, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			value.number, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			value.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %v of field %v", typ, num)
+		}
+		if n < 0 {
+			t.Fatalf("invalid value of field %v: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		message[num] = append(message[num], value)
+	}
+	return message
+}
+
+// messages decodes the repeated message field
+func (m protoMessage) messages(t *testing.T, num protowire.Number) []protoMessage {
+	t.Helper()
+
+	var messages []protoMessage
+	for _, value := range m[num] {
+		messages = append(messages, decodeMessage(t, value.bytes))
+	}
+	return messages
+}
+
+// message decodes the only message of the field
+func (m protoMessage) message(t *testing.T, num protowire.Number) protoMessage {
+	t.Helper()
+
+	messages := m.messages(t, num)
+	if len(messages) != 1 {
+		t.Fatalf("Expected one message in field %v, got %v", num, len(messages))
+	}
+	return messages[0]
+}
+
+func (m protoMessage) number(num protowire.Number) uint64 {
+	if len(m[num]) == 0 {
+		return 0
+	}
+	return m[num][0].number
+}
+
+func (m protoMessage) bytes(num protowire.Number) []byte {
+	if len(m[num]) == 0 {
+		return nil
+	}
+	return m[num][0].bytes
+}
+
+func (m protoMessage) string(num protowire.Number) string {
+	return string(m.bytes(num))
+}
+
+// attributes decodes the repeated KeyValue field with string values (opentelemetry/proto/common/v1/common.proto),
+// the field numbers are spelled out so the tests don't rely on the constants of the exporter
+func (m protoMessage) attributes(t *testing.T, num protowire.Number) map[string]string {
+	t.Helper()
+
+	attributes := map[string]string{}
+	for _, keyValue := range m.messages(t, num) {
+		// KeyValue.key, KeyValue.value and AnyValue.string_value
+		attributes[keyValue.string(1)] = keyValue.message(t, 2).string(1)
+	}
+	return attributes
+}
@@ -0,0 +1,102 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"log"
+	"sync"
+	"time"
+)
+
+// at most this many batches cut short by the deadline of an invocation are kept for the next report, the oldest go first
+const maxRetainedBatches = 16
+
+type invocationDeadlineKey struct{}
+
+// WithInvocationDeadline bounds the context by the deadline of an invocation,
+// a batch cut short by it is kept for the next report, unlike one which failed on the timeout of its destination
+func WithInvocationDeadline(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	return context.WithDeadline(context.WithValue(ctx, invocationDeadlineKey{}, deadline), deadline)
+}
+
+func cutByInvocationDeadline(ctx context.Context) bool {
+	deadline, ok := ctx.Value(invocationDeadlineKey{}).(time.Time)
+	return ok && ctx.Err() != nil && !time.Now().Before(deadline)
+}
+
+// retainingSink keeps the batches cut short by the deadline of an invocation in memory, and sends them (oldest first)
+// before the next batch. It's used without the spool, the batches which failed otherwise are dropped.
+type retainingSink struct {
+	next        sfxclient.Sink
+	destination string
+
+	mu       sync.Mutex
+	retained []retainedBatch
+}
+
+// retainedBatch holds the encoded request of a requestSink, so sending it again doesn't change the state of the sink
+type retainedBatch struct {
+	points []*datapoint.Datapoint
+	body   []byte
+}
+
+func newRetainingSink(next sfxclient.Sink, destination string) *retainingSink {
+	return &retainingSink{next: next, destination: destination}
+}
+
+func (sink *retainingSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if next, ok := sink.next.(requestSink); ok {
+		if body := next.encodeRequest(points); body != nil {
+			sink.retained = append(sink.retained, retainedBatch{body: body})
+		}
+	} else {
+		sink.retained = append(sink.retained, retainedBatch{points: points})
+	}
+
+	var firstErr error
+	for len(sink.retained) > 0 {
+		err := sink.send(ctx, sink.retained[0])
+		if err != nil && cutByInvocationDeadline(ctx) {
+			sink.trim()
+			log.Printf("keeping %v batches for %v, cut short by the deadline of the invocation\n", len(sink.retained), sink.destination)
+			return err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		sink.retained = sink.retained[1:]
+	}
+	return firstErr
+}
+
+func (sink *retainingSink) send(ctx context.Context, batch retainedBatch) error {
+	if batch.body != nil {
+		return sink.next.(requestSink).sendRequest(ctx, batch.body)
+	}
+	return sink.next.AddDatapoints(ctx, batch.points)
+}
+
+func (sink *retainingSink) trim() {
+	if dropped := len(sink.retained) - maxRetainedBatches; dropped > 0 {
+		log.Printf("dropping %v batches for %v, %v are already kept\n", dropped, sink.destination, maxRetainedBatches)
+		sink.retained = sink.retained[dropped:]
+	}
+}
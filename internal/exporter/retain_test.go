@@ -0,0 +1,123 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetainingSinkKeepsBatchesCutByTheDeadline(t *testing.T) {
+	next := &stubSink{delay: time.Minute}
+	sink := newRetainingSink(next, "default")
+
+	late := []*datapoint.Datapoint{sfxclient.Gauge("late", nil, 1)}
+	ctx, cancel := WithInvocationDeadline(context.Background(), time.Now().Add(10*time.Millisecond))
+	defer cancel()
+
+	if err := sink.AddDatapoints(ctx, late); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+	if len(sink.retained) != 1 {
+		t.Fatalf("Expected the batch cut short by the deadline to be kept, got `%v`", len(sink.retained))
+	}
+
+	var sent [][]*datapoint.Datapoint
+	sink.next = &recordingSink{batches: &sent}
+
+	fresh := []*datapoint.Datapoint{sfxclient.Gauge("fresh", nil, 1)}
+	if err := sink.AddDatapoints(context.Background(), fresh); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(sent, [][]*datapoint.Datapoint{late, fresh}) || len(sink.retained) != 0 {
+		t.Errorf("Expected the kept batch to be sent before the fresh one, got `%v`", sent)
+	}
+}
+
+func TestRetainingSinkDropsOtherFailures(t *testing.T) {
+	next := &stubSink{delay: time.Minute}
+	sink := newRetainingSink(next, "default")
+
+	// the timeout of the destination isn't the deadline of the invocation
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := sink.AddDatapoints(ctx, []*datapoint.Datapoint{sfxclient.Gauge("slow", nil, 1)}); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+
+	next.delay, next.err = 0, errors.New("rejected")
+	if err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{sfxclient.Gauge("rejected", nil, 1)}); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+
+	if len(sink.retained) != 0 {
+		t.Errorf("Expected only the batches cut short by the deadline to be kept, got `%v`", len(sink.retained))
+	}
+}
+
+// encodingSink numbers its requests, like the state of the cumulative totals changes with every encoded request
+type encodingSink struct {
+	encoded int
+	delay   time.Duration
+	sent    []string
+}
+
+func (s *encodingSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	return s.sendRequest(ctx, s.encodeRequest(points))
+}
+
+func (s *encodingSink) encodeRequest(points []*datapoint.Datapoint) []byte {
+	s.encoded++
+	return []byte(fmt.Sprintf("request-%d", s.encoded))
+}
+
+func (s *encodingSink) sendRequest(ctx context.Context, body []byte) error {
+	select {
+	case <-time.After(s.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.sent = append(s.sent, string(body))
+	return nil
+}
+
+func TestRetainingSinkResendsEncodedRequests(t *testing.T) {
+	next := &encodingSink{delay: time.Minute}
+	sink := newRetainingSink(next, "default")
+
+	ctx, cancel := WithInvocationDeadline(context.Background(), time.Now().Add(10*time.Millisecond))
+	defer cancel()
+
+	if err := sink.AddDatapoints(ctx, []*datapoint.Datapoint{sfxclient.Counter("late", nil, 1)}); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+
+	next.delay = 0
+	if err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{sfxclient.Counter("fresh", nil, 1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"request-1", "request-2"}; next.encoded != 2 || !reflect.DeepEqual(next.sent, expected) {
+		t.Errorf("Expected `%v` encoded once, got `%v` of `%v` requests", expected, next.sent, next.encoded)
+	}
+}
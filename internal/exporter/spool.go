@@ -0,0 +1,238 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolDropped = "lambda.extension.spool.dropped"
+const spoolSpooled = "lambda.extension.spool.spooled"
+const dimDestination = "destination"
+
+const spoolFileSuffix = ".json"
+const requestFileSuffix = ".request"
+
+// requestSink is implemented by the sinks keeping state between the requests (e.g. cumulative totals or delta intervals),
+// their requests are spooled as they were encoded, so resending one doesn't change the state again.
+// encodeRequest returns nil when there's nothing to send.
+type requestSink interface {
+	sfxclient.Sink
+	encodeRequest(points []*datapoint.Datapoint) []byte
+	sendRequest(ctx context.Context, body []byte) error
+}
+
+// spoolingSink keeps the batches which failed to be sent in a bounded directory
+// and retries them (oldest first) after the next successful send, including the one on shutdown.
+// The batches older than maxAge, or not fitting into maxBytes, are dropped.
+type spoolingSink struct {
+	next        sfxclient.Sink
+	destination string
+	dir         string
+	maxBytes    int64
+	maxAge      time.Duration
+
+	mu      sync.Mutex
+	seq     int64
+	dropped int64
+	spooled int64
+}
+
+type spooledBatch struct {
+	path    string
+	size    int64
+	created time.Time
+}
+
+func newSpoolingSink(next sfxclient.Sink, destination, dir string, maxBytes int64, maxAge time.Duration) *spoolingSink {
+	return &spoolingSink{
+		next:        next,
+		destination: destination,
+		dir:         filepath.Join(dir, sanitizeName(destination, false)),
+		maxBytes:    maxBytes,
+		maxAge:      maxAge,
+	}
+}
+
+func (sink *spoolingSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	if next, ok := sink.next.(requestSink); ok {
+		if body := next.encodeRequest(points); body != nil {
+			if err := next.sendRequest(ctx, body); err != nil {
+				sink.spool(body, len(points), requestFileSuffix)
+				return err
+			}
+		}
+	} else if err := sink.next.AddDatapoints(ctx, points); err != nil {
+		if len(points) > 0 {
+			sink.spoolDatapoints(points)
+		}
+		return err
+	}
+
+	sink.retrySpooled(ctx)
+	return nil
+}
+
+func (sink *spoolingSink) spoolDatapoints(points []*datapoint.Datapoint) {
+	body, err := json.Marshal(points)
+	if err != nil {
+		log.Printf("can't encode the batch for %v: %v\n", sink.destination, err)
+		sink.dropped++
+		return
+	}
+
+	sink.spool(body, len(points), spoolFileSuffix)
+}
+
+func (sink *spoolingSink) spool(body []byte, points int, suffix string) {
+	size := int64(len(body))
+	if size > sink.maxBytes {
+		log.Printf("batch for %v is bigger than the spool (%v bytes), dropping it\n", sink.destination, size)
+		sink.dropped++
+		return
+	}
+
+	if err := os.MkdirAll(sink.dir, 0700); err != nil {
+		log.Printf("can't create the spool directory %v: %v\n", sink.dir, err)
+		sink.dropped++
+		return
+	}
+
+	// make room for the new batch, the oldest ones go first
+	batches := sink.spooledBatches()
+	total := size
+	for _, batch := range batches {
+		total += batch.size
+	}
+	for len(batches) > 0 && total > sink.maxBytes {
+		total -= batches[0].size
+		sink.drop(batches[0], "spool is full")
+		batches = batches[1:]
+	}
+
+	sink.seq++
+	path := filepath.Join(sink.dir, fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), sink.seq, suffix))
+	if err := ioutil.WriteFile(path, body, 0600); err != nil {
+		log.Printf("can't spool the batch for %v: %v\n", sink.destination, err)
+		sink.dropped++
+		return
+	}
+
+	sink.spooled++
+	log.Printf("spooled %v datapoints for %v\n", points, sink.destination)
+}
+
+func (sink *spoolingSink) retrySpooled(ctx context.Context) {
+	for _, batch := range sink.spooledBatches() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(batch.created) > sink.maxAge {
+			sink.drop(batch, "too old")
+			continue
+		}
+
+		body, err := ioutil.ReadFile(batch.path)
+		if err != nil {
+			sink.drop(batch, err.Error())
+			continue
+		}
+
+		if strings.HasSuffix(batch.path, requestFileSuffix) {
+			next, ok := sink.next.(requestSink)
+			if !ok {
+				sink.drop(batch, "the exporter doesn't accept encoded requests")
+				continue
+			}
+			err = next.sendRequest(ctx, body)
+		} else {
+			var points []*datapoint.Datapoint
+			if err := json.Unmarshal(body, &points); err != nil {
+				sink.drop(batch, "invalid spooled batch: "+err.Error())
+				continue
+			}
+			err = sink.next.AddDatapoints(ctx, points)
+		}
+
+		if err != nil {
+			log.Printf("failed to resend a spooled batch to %v: %v\n", sink.destination, err)
+			return
+		}
+
+		log.Printf("resent a spooled batch to %v\n", sink.destination)
+		if err := os.Remove(batch.path); err != nil {
+			log.Printf("can't remove a spooled batch: %v\n", err)
+		}
+	}
+}
+
+func (sink *spoolingSink) drop(batch spooledBatch, reason string) {
+	log.Printf("dropping a spooled batch for %v: %v\n", sink.destination, reason)
+	sink.dropped++
+	if err := os.Remove(batch.path); err != nil {
+		log.Printf("can't remove a spooled batch: %v\n", err)
+	}
+}
+
+// spooledBatches returns the batches ordered from the oldest, the file names start with the creation time
+func (sink *spoolingSink) spooledBatches() []spooledBatch {
+	files, err := ioutil.ReadDir(sink.dir)
+	if err != nil {
+		return nil
+	}
+
+	var batches []spooledBatch
+	for _, file := range files {
+		if file.IsDir() || (!strings.HasSuffix(file.Name(), spoolFileSuffix) && !strings.HasSuffix(file.Name(), requestFileSuffix)) {
+			continue
+		}
+		batches = append(batches, spooledBatch{
+			path:    filepath.Join(sink.dir, file.Name()),
+			size:    file.Size(),
+			created: file.ModTime(),
+		})
+	}
+
+	sort.Slice(batches, func(i, j int) bool { return batches[i].path < batches[j].path })
+	return batches
+}
+
+func (sink *spoolingSink) Datapoints() []*datapoint.Datapoint {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	defer func() { sink.dropped, sink.spooled = 0, 0 }()
+	dims := map[string]string{dimDestination: sink.destination}
+	return []*datapoint.Datapoint{
+		sfxclient.Counter(spoolDropped, dims, sink.dropped),
+		sfxclient.Counter(spoolSpooled, dims, sink.spooled),
+	}
+}
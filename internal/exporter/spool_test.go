@@ -0,0 +1,192 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/snappy"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSpoolingSinkResendsFailedBatches(t *testing.T) {
+	next := &stubSink{err: errors.New("unavailable")}
+	sink := newSpoolingSink(next, "default", t.TempDir(), 1024*1024, time.Hour)
+
+	failed := sfxclient.Gauge("failed", map[string]string{"k": "v"}, 42)
+	failed.Timestamp = time.Unix(1600000000, 0)
+
+	if err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{failed}); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+	if len(sink.spooledBatches()) != 1 {
+		t.Fatalf("Expected the batch to be spooled")
+	}
+
+	var sent [][]*datapoint.Datapoint
+	recording := &recordingSink{batches: &sent}
+	sink.next = recording
+
+	if err := sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{sfxclient.Gauge("fresh", nil, 1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 2 || sent[1][0].Metric != "failed" {
+		t.Fatalf("Expected the spooled batch to be resent after the fresh one, got %v", sent)
+	}
+	resent := sent[1][0]
+	if resent.Value.String() != "42" || resent.Dimensions["k"] != "v" || !resent.Timestamp.Equal(failed.Timestamp) {
+		t.Errorf("Expected the spooled datapoint to be kept intact, got %v", resent)
+	}
+	if len(sink.spooledBatches()) != 0 {
+		t.Errorf("Expected the spool to be empty")
+	}
+}
+
+func TestSpoolingSinkIsBounded(t *testing.T) {
+	sink := newSpoolingSink(&stubSink{err: errors.New("unavailable")}, "default", t.TempDir(), 300, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		_ = sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{sfxclient.Gauge("metric", nil, int64(i))})
+	}
+
+	var total int64
+	for _, batch := range sink.spooledBatches() {
+		total += batch.size
+	}
+	if total > 300 {
+		t.Errorf("Expected at most 300 bytes to be spooled, got %v", total)
+	}
+
+	dropped := sink.Datapoints()[0]
+	if dropped.Metric != spoolDropped || dropped.Value.String() == "0" {
+		t.Errorf("Expected the dropped batches to be counted, got %v", dropped)
+	}
+}
+
+type recordingSink struct {
+	batches *[][]*datapoint.Datapoint
+}
+
+func (rs *recordingSink) AddDatapoints(_ context.Context, points []*datapoint.Datapoint) error {
+	*rs.batches = append(*rs.batches, points)
+	return nil
+}
+
+// failingOnceServer rejects the first request and records the bodies of the accepted ones
+func failingOnceServer(t *testing.T, decode func([]byte) ([]byte, error)) (*httptest.Server, *[][]byte) {
+	var bodies [][]byte
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		decoded, err := decode(body)
+		if err != nil {
+			t.Errorf("can't decode the request: %v", err)
+		}
+		bodies = append(bodies, decoded)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &bodies
+}
+
+func TestSpoolingSinkResendsPrometheusRequests(t *testing.T) {
+	server, bodies := failingOnceServer(t, func(body []byte) ([]byte, error) { return snappy.Decode(nil, body) })
+	sink := newSpoolingSink(newPrometheusSink(server.URL, "", time.Second), "default", t.TempDir(), 1024*1024, time.Hour)
+
+	add := func(value int64) error {
+		dp := datapoint.New("invocations", nil, datapoint.NewIntValue(value), datapoint.Count, time.Now())
+		return sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{dp})
+	}
+
+	if err := add(3); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+	if err := add(4); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*bodies) != 2 {
+		t.Fatalf("Expected `%v` requests, got `%v`", 2, len(*bodies))
+	}
+
+	// the fresh request has the total of both, the resent one the total it was encoded with
+	for i, expected := range []float64{7, 3} {
+		sample := decodeMessage(t, (*bodies)[i]).message(t, writeRequestTimeseries).message(t, timeSeriesSamples)
+		if value := math.Float64frombits(sample.number(sampleValue)); value != expected {
+			t.Errorf("Expected `%v` in request %v, got `%v`", expected, i, value)
+		}
+	}
+	if len(sink.spooledBatches()) != 0 {
+		t.Errorf("Expected the spool to be empty")
+	}
+}
+
+func TestSpoolingSinkResendsOtlpRequests(t *testing.T) {
+	server, bodies := failingOnceServer(t, func(body []byte) ([]byte, error) { return body, nil })
+	otlp := newOtlpSink(server.URL, "", time.Second)
+	sink := newSpoolingSink(otlp, "default", t.TempDir(), 1024*1024, time.Hour)
+
+	add := func(value int64) error {
+		dp := datapoint.New("invocations", nil, datapoint.NewIntValue(value), datapoint.Count, time.Now())
+		return sink.AddDatapoints(context.Background(), []*datapoint.Datapoint{dp})
+	}
+
+	if err := add(3); err == nil {
+		t.Fatal("Expected the error to be returned")
+	}
+	failedAt := time.Now()
+	if err := add(4); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*bodies) != 2 {
+		t.Fatalf("Expected `%v` requests, got `%v`", 2, len(*bodies))
+	}
+
+	points := make([]protoMessage, 0, 2)
+	for _, body := range *bodies {
+		// resource_metrics, scope_metrics and metrics of opentelemetry/proto/metrics/v1/metrics.proto
+		metric := decodeMessage(t, body).message(t, 1).message(t, 2).message(t, 2)
+		// Metric.sum, Sum.data_points
+		points = append(points, metric.message(t, 7).message(t, 1))
+	}
+
+	// NumberDataPoint.as_int and NumberDataPoint.start_time_unix_nano
+	fresh, resent := points[0], points[1]
+	if fresh.number(6) != 4 || resent.number(6) != 3 {
+		t.Errorf("Expected `4` and `3`, got `%v` and `%v`", fresh.number(6), resent.number(6))
+	}
+	// the resent interval starts with the process and the fresh one where the failed one ended
+	if start := resent.number(2); start != uint64(otlp.processStart.UnixNano()) {
+		t.Errorf("Expected the resent interval to start with the process, got `%v`", start)
+	}
+	if start := fresh.number(2); start <= uint64(otlp.processStart.UnixNano()) || start > uint64(failedAt.UnixNano()) {
+		t.Errorf("Expected the fresh interval to start after the failed one, got `%v`", start)
+	}
+}
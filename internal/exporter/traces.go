@@ -0,0 +1,48 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/signalfx/golib/v3/trace"
+	"github.com/splunk/lambda-extension/internal/config"
+	"log"
+	"time"
+)
+
+// NewTraceSink creates the sink sending the spans to the configured traces endpoint, nil when the traces are disabled.
+func NewTraceSink(configuration *config.Configuration) trace.Sink {
+	if !configuration.TracesEnabled {
+		return nil
+	}
+
+	switch configuration.TracesExporter {
+	case config.TracesExporterOTLP:
+		return newOtlpTraceSink(configuration.TracesUrl, configuration.SplunkToken, configuration.ReportingTimeout)
+	case config.TracesExporterSAPM:
+		return newSapmSink(configuration.TracesUrl, configuration.SplunkToken, configuration.ReportingTimeout)
+	default:
+		log.Printf("unknown traces exporter: %v, using %v\n", configuration.TracesExporter, config.TracesExporterSAPM)
+		return newSapmSink(configuration.TracesUrl, configuration.SplunkToken, configuration.ReportingTimeout)
+	}
+}
+
+func newSapmSink(url, token string, timeout time.Duration) *sfxclient.HTTPSink {
+	sink := sfxclient.NewHTTPSink(sfxclient.WithSAPMTraceExporter())
+	sink.TraceEndpoint = url
+	sink.AuthToken = token
+	sink.Client.Timeout = timeout
+	return sink
+}
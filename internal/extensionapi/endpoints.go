@@ -19,14 +19,20 @@ import (
 	"os"
 )
 
+const runtimeApiEnv = "AWS_LAMBDA_RUNTIME_API"
+
 type apiEndpoints struct {
-	register, next, initError, exitError string
+	register, next, initError, exitError, telemetry string
 }
 
-var apiHost = os.Getenv("AWS_LAMBDA_RUNTIME_API")
+// the host is read on registration (not on init), so the API can be emulated in tests
+func newEndpoints() apiEndpoints {
+	apiHost := os.Getenv(runtimeApiEnv)
 
-var endpoints = apiEndpoints{
-	register:  fmt.Sprintf("http://%v/2020-01-01/extension/register", apiHost),
-	next:      fmt.Sprintf("http://%v/2020-01-01/extension/event/next", apiHost),
-	initError: fmt.Sprintf("http://%v/2020-01-01/extension/init/error", apiHost),
-	exitError: fmt.Sprintf("http://%v/2020-01-01/extension/exit/error", apiHost)}
+	return apiEndpoints{
+		register:  fmt.Sprintf("http://%v/2020-01-01/extension/register", apiHost),
+		next:      fmt.Sprintf("http://%v/2020-01-01/extension/event/next", apiHost),
+		initError: fmt.Sprintf("http://%v/2020-01-01/extension/init/error", apiHost),
+		exitError: fmt.Sprintf("http://%v/2020-01-01/extension/exit/error", apiHost),
+		telemetry: fmt.Sprintf("http://%v/2022-07-01/telemetry", apiHost)}
+}
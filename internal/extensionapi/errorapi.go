@@ -24,13 +24,13 @@ import (
 func (api RegisteredApi) InitError(errorType string) {
 	log.Println("Reporting an init error: ", errorType)
 
-	api.reportError(endpoints.initError, errorType)
+	api.reportError(api.endpoints.initError, errorType)
 }
 
 func (api RegisteredApi) ExitError(errorType string) {
 	log.Println("Reporting an exit error: ", errorType)
 
-	api.reportError(endpoints.exitError, errorType)
+	api.reportError(api.endpoints.exitError, errorType)
 }
 
 func (api RegisteredApi) reportError(endpoint, errorType string) {
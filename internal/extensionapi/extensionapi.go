@@ -26,6 +26,7 @@ import (
 	"net/http"
 )
 
+// extensions can't register for RESTORE events of SnapStart, the restores are observed via the Telemetry API
 const (
 	invokeType   = "INVOKE"
 	shutdownType = "SHUTDOWN"
@@ -49,6 +50,7 @@ type RegisteredApi struct {
 	ExtensionName string
 
 	extensionId string
+	endpoints   apiEndpoints
 
 	registerResponse
 }
@@ -74,6 +76,8 @@ func Register(enabled bool, name string, configuration *config.Configuration) (*
 	}
 	client := &http.Client{Transport: transportCfg}
 
+	endpoints := newEndpoints()
+
 	req, err := http.NewRequest(http.MethodPost, endpoints.register, bytes.NewBuffer(rb))
 
 	if err != nil {
@@ -124,13 +128,14 @@ func Register(enabled bool, name string, configuration *config.Configuration) (*
 	return &RegisteredApi{
 		ExtensionName:    name,
 		extensionId:      id[0],
+		endpoints:        endpoints,
 		registerResponse: *regResponse}, nil
 }
 
 func (api RegisteredApi) NextEvent() (*Event, shutdown.Condition) {
 	log.Println("Waiting for event")
 
-	req, err := http.NewRequest(http.MethodGet, endpoints.next, nil)
+	req, err := http.NewRequest(http.MethodGet, api.endpoints.next, nil)
 
 	if err != nil {
 		return nil, shutdown.Api(fmt.Sprintf("can't create http request: %v", err))
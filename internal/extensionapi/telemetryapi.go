@@ -0,0 +1,104 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensionapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+const telemetrySchemaVersion = "2022-12-13"
+
+const (
+	TelemetryPlatform = "platform"
+	TelemetryFunction = "function"
+)
+
+type telemetryBuffering struct {
+	MaxItems  int `json:"maxItems"`
+	MaxBytes  int `json:"maxBytes"`
+	TimeoutMs int `json:"timeoutMs"`
+}
+
+type telemetryDestination struct {
+	Protocol string `json:"protocol"`
+	URI      string `json:"URI"`
+}
+
+type telemetrySubscription struct {
+	SchemaVersion string               `json:"schemaVersion"`
+	Types         []string             `json:"types"`
+	Buffering     telemetryBuffering   `json:"buffering"`
+	Destination   telemetryDestination `json:"destination"`
+}
+
+// the smallest allowed values, so the events are delivered as soon as possible
+var defaultBuffering = telemetryBuffering{
+	MaxItems:  1000,
+	MaxBytes:  262144,
+	TimeoutMs: 25,
+}
+
+// SubscribeTelemetry asks the Telemetry API to push the given event types to the destination URI.
+// A failed subscription is not fatal, the extension keeps working without the telemetry metrics.
+func (api RegisteredApi) SubscribeTelemetry(uri string, types []string) error {
+	log.Printf("Subscribing to telemetry %v at %v\n", types, uri)
+
+	rb, err := json.Marshal(telemetrySubscription{
+		SchemaVersion: telemetrySchemaVersion,
+		Types:         types,
+		Buffering:     defaultBuffering,
+		Destination:   telemetryDestination{Protocol: "HTTP", URI: uri},
+	})
+
+	if err != nil {
+		return fmt.Errorf("can't marshall body: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, api.endpoints.telemetry, bytes.NewBuffer(rb))
+
+	if err != nil {
+		return fmt.Errorf("can't create http request: %v", err)
+	}
+
+	req.Header.Set("Lambda-Extension-Identifier", api.extensionId)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("can't subscribe: %v", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read body: %v", err)
+	}
+
+	log.Printf("Telemetry subscription response: %v\n", string(bodyBytes))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to subscribe, API returned: %v", resp.Status)
+	}
+
+	log.Println("Subscribing to telemetry [DONE]")
+
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+const environmentActiveTime = "lambda.function.active_time"
+const environmentFrozenTime = "lambda.function.frozen_time"
+
+// the telemetry of an invocation can arrive after the next one started, the periods are kept for a while to be corrected
+const maxClosedPeriods = 16
+
+// activityTracker splits the lifetime of the environment into the active and the frozen time.
+// Every event starts an active period, which ends when both the function and the extension are done with it,
+// the rest of the time until the next event the environment is frozen.
+// When the end of the function isn't reported by the Telemetry API, the deadline of the invocation is used.
+// The initialization is counted as active.
+type activityTracker struct {
+	mu sync.Mutex
+
+	current *activePeriod
+	closed  map[string]*activePeriod
+	order   []string
+
+	active time.Duration
+	frozen time.Duration
+}
+
+type activePeriod struct {
+	requestId string
+	since     time.Time
+	deadline  time.Time
+	// when the extension was done with the event and when the function was
+	idleAt time.Time
+	doneAt time.Time
+	// the end assumed when the period was closed
+	end time.Time
+}
+
+// started is called when the environment is initialized
+func (at *activityTracker) started(now time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	at.current = &activePeriod{since: now}
+	at.closed = make(map[string]*activePeriod)
+	at.order = nil
+	at.active, at.frozen = 0, 0
+}
+
+// restored is called when the environment is restored from a snapshot, which is usually reported after the first
+// invocation started. The time before the restore is dropped, the restore itself is active until the first invocation.
+func (at *activityTracker) restored(t time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	at.active, at.frozen = 0, 0
+	if at.current == nil {
+		return
+	}
+	if at.current.since.Before(t) {
+		// still the period of the initialization before the snapshot
+		at.current.since = t
+		return
+	}
+	at.active = at.current.since.Sub(t)
+}
+
+// thawed closes the current period and starts a new one, the deadline is zero for the SHUTDOWN event
+func (at *activityTracker) thawed(now time.Time, requestId string, deadline time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if at.current != nil {
+		at.close(now)
+	}
+	at.current = &activePeriod{requestId: requestId, since: now, deadline: deadline}
+}
+
+// idle is called when the extension is done with the event
+func (at *activityTracker) idle(now time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if at.current != nil {
+		at.current.idleAt = now
+	}
+}
+
+// runtimeDone is called when the function is done with the request
+func (at *activityTracker) runtimeDone(requestId string, t time.Time) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if at.current != nil && at.current.requestId == requestId {
+		at.current.doneAt = t
+		return
+	}
+
+	period, found := at.closed[requestId]
+	if !found {
+		return
+	}
+	delete(at.closed, requestId)
+
+	period.doneAt = t
+	correction := period.end.Sub(period.clamp(period.knownEnd(), period.end))
+	at.active -= correction
+	at.frozen += correction
+}
+
+// times closes the current period at now
+func (at *activityTracker) times(now time.Time) (active, frozen time.Duration) {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	if at.current != nil {
+		at.close(now)
+		at.current = nil
+	}
+	return at.active, at.frozen
+}
+
+func (at *activityTracker) close(now time.Time) {
+	period := at.current
+
+	end := now
+	if known := period.knownEnd(); !known.IsZero() {
+		end = known
+	} else if !period.deadline.IsZero() {
+		end = period.deadline
+	}
+	period.end = period.clamp(end, now)
+
+	at.active += period.end.Sub(period.since)
+	at.frozen += now.Sub(period.end)
+
+	if period.requestId != "" && period.doneAt.IsZero() {
+		at.remember(period)
+	}
+}
+
+func (at *activityTracker) remember(period *activePeriod) {
+	if len(at.order) >= maxClosedPeriods {
+		delete(at.closed, at.order[0])
+		at.order = at.order[1:]
+	}
+	at.closed[period.requestId] = period
+	at.order = append(at.order, period.requestId)
+}
+
+// knownEnd is zero until both the function and the extension are done
+func (p *activePeriod) knownEnd() time.Time {
+	if p.idleAt.IsZero() || p.doneAt.IsZero() {
+		return time.Time{}
+	}
+	if p.doneAt.After(p.idleAt) {
+		return p.doneAt
+	}
+	return p.idleAt
+}
+
+func (p *activePeriod) clamp(t, max time.Time) time.Time {
+	if t.IsZero() || t.After(max) {
+		return max
+	}
+	if t.Before(p.since) {
+		return p.since
+	}
+	return t
+}
@@ -0,0 +1,47 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActivity(t *testing.T) {
+	t0 := time.Now()
+	ms := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Millisecond) }
+
+	at := &activityTracker{}
+	at.started(t0)
+
+	at.thawed(ms(100), "req-1", ms(1100))
+	at.idle(ms(150))
+	at.runtimeDone("req-1", ms(200))
+
+	// the runtime done of req-2 arrives only after the next event
+	at.thawed(ms(1000), "req-2", ms(1500))
+	at.idle(ms(1050))
+	at.thawed(ms(3000), "", time.Time{})
+	at.runtimeDone("req-2", ms(1100))
+
+	active, frozen := at.times(ms(3010))
+
+	if active != 310*time.Millisecond {
+		t.Errorf("Expected `%v`, got `%v`", 310*time.Millisecond, active)
+	}
+	if frozen != 2700*time.Millisecond {
+		t.Errorf("Expected `%v`, got `%v`", 2700*time.Millisecond, frozen)
+	}
+}
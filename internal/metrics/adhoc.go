@@ -0,0 +1,49 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/signalfx/golib/v3/datapoint"
+	"log"
+	"sync"
+)
+
+// adhocCollector reports the datapoints added since the last report,
+// it's safe for concurrent use, so it can be fed by the listeners' goroutines.
+type adhocCollector struct {
+	mu       sync.Mutex
+	limit    int
+	adhocDps []*datapoint.Datapoint
+}
+
+func (ac *adhocCollector) add(dps ...*datapoint.Datapoint) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if ac.limit > 0 && len(ac.adhocDps)+len(dps) > ac.limit {
+		log.Printf("dropping %v datapoints, at most %v can be buffered between reports\n", len(dps), ac.limit)
+		return
+	}
+
+	ac.adhocDps = append(ac.adhocDps, dps...)
+}
+
+func (ac *adhocCollector) Datapoints() []*datapoint.Datapoint {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	defer func() { ac.adhocDps = nil }()
+	return ac.adhocDps
+}
@@ -0,0 +1,40 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/signalfx/golib/v3/datapoint"
+)
+
+// the function code can't make the extension buffer an unbounded number of datapoints
+const maxCustomDatapoints = 10000
+
+func newCustomCollector() *adhocCollector {
+	return &adhocCollector{limit: maxCustomDatapoints}
+}
+
+// AddDatapoints accepts the custom datapoints sent by the function code.
+// They get the dimensions of the function ARN being invoked at the moment,
+// or the environment's dimensions if they were sent before the first invocation.
+func (emitter *MetricEmitter) AddDatapoints(dps []*datapoint.Datapoint) {
+	emitter.mu.Lock()
+	collector := emitter.customMetrics
+	if function, found := emitter.arnToFunction[emitter.currentArn]; found {
+		collector = function.custom
+	}
+	emitter.mu.Unlock()
+
+	collector.add(dps...)
+}
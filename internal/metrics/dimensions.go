@@ -29,15 +29,18 @@ const dimArn = "aws_arn"
 const dimQualifier = "aws_function_qualifier"
 const dimRuntime = "aws_function_runtime"
 const dimAwsUniqueId = "AWSUniqueId"
+const dimColdStart = "cold_start"
+const dimStatus = "status"
+const dimInitType = "init_type"
 
-func (emitter MetricEmitter) dims(functionArn string) map[string]string {
+func (emitter *MetricEmitter) dims(functionArn string) map[string]string {
 	parsedArn, err := arn.Parse(functionArn)
 
 	if err != nil {
 		log.Panicf("can't parse ARN: %v\n", functionArn)
 	}
 
-	return map[string]string{
+	dims := map[string]string{
 		dimRegion:          parsedArn.Region,
 		dimAccountId:       parsedArn.AccountID,
 		dimFunctionName:    emitter.functionName,
@@ -47,4 +50,42 @@ func (emitter MetricEmitter) dims(functionArn string) map[string]string {
 		dimRuntime:         os.Getenv(awsExecutionEnv),
 		dimAwsUniqueId:     emitter.buildAWSUniqueId(parsedArn),
 	}
+
+	// the configured dimensions can't override the ones of the function
+	for k, v := range emitter.config.Dimensions {
+		if _, found := dims[k]; !found {
+			dims[k] = v
+		}
+	}
+
+	return dims
+}
+
+// filterDims applies the configured include and exclude lists, then the renames
+func (emitter *MetricEmitter) filterDims(dims map[string]string) map[string]string {
+	c := emitter.config
+
+	if len(c.DimensionsInclude) > 0 {
+		included := make(map[string]string, len(c.DimensionsInclude))
+		for _, k := range c.DimensionsInclude {
+			if v, found := dims[k]; found {
+				included[k] = v
+			}
+		}
+		dims = included
+	}
+
+	for _, k := range c.DimensionsExclude {
+		delete(dims, k)
+	}
+
+	filtered := make(map[string]string, len(dims))
+	for k, v := range dims {
+		if renamed, found := c.DimensionsRename[k]; found {
+			k = renamed
+		}
+		filtered[k] = v
+	}
+
+	return filtered
 }
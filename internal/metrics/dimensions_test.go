@@ -0,0 +1,67 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/splunk/lambda-extension/internal/config"
+	"testing"
+)
+
+func TestConfiguredDimensions(t *testing.T) {
+	emitter := &MetricEmitter{
+		config:          &config.Configuration{Dimensions: map[string]string{"team": "payments", dimFunctionName: "overridden"}},
+		functionName:    "helloworld",
+		functionVersion: "1",
+	}
+
+	dims := emitter.dims(testArn)
+
+	if dims["team"] != "payments" {
+		t.Errorf("Expected `%v`, got `%v`", "payments", dims["team"])
+	}
+	if dims[dimFunctionName] != "helloworld" {
+		t.Errorf("Expected `%v`, got `%v`", "helloworld", dims[dimFunctionName])
+	}
+}
+
+func TestFilteredDimensions(t *testing.T) {
+	emitter := &MetricEmitter{
+		config: &config.Configuration{
+			DimensionsExclude: []string{dimArn, dimAwsUniqueId},
+			DimensionsRename:  map[string]string{dimFunctionName: "function"},
+		},
+		functionName:    "helloworld",
+		functionVersion: "1",
+	}
+
+	dims := emitter.filterDims(emitter.dims(testArn))
+
+	for _, k := range []string{dimArn, dimAwsUniqueId, dimFunctionName} {
+		if _, found := dims[k]; found {
+			t.Errorf("Unexpected dimension %v in %v", k, dims)
+		}
+	}
+	if dims["function"] != "helloworld" || dims[dimQualifier] != "live" {
+		t.Errorf("Unexpected dimensions: %v", dims)
+	}
+
+	emitter.config.DimensionsInclude = []string{dimFunctionName, dimRegion, dimArn}
+
+	dims = emitter.filterDims(emitter.dims(testArn))
+
+	if len(dims) != 2 || dims["function"] != "helloworld" || dims[dimRegion] != "us-east-1" {
+		t.Errorf("Unexpected dimensions: %v", dims)
+	}
+}
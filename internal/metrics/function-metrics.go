@@ -0,0 +1,42 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+// functionMetrics groups the callbacks reported with the dimensions of a single function ARN
+type functionMetrics struct {
+	invocations *invocationsCounter
+	reports     *reportsCollector
+	outcomes    *outcomesCounter
+	custom      *adhocCollector
+}
+
+func (emitter *MetricEmitter) registerFunction(functionArn string) *functionMetrics {
+	function := &functionMetrics{
+		invocations: &invocationsCounter{coldStartDimension: emitter.config.ColdStartDimension},
+		reports:     newReportsCollector(emitter.config.Percentiles),
+		outcomes:    &outcomesCounter{},
+		custom:      newCustomCollector(),
+	}
+
+	emitter.arnToFunction[functionArn] = function
+
+	emitter.scheduler.GroupedDefaultDimensions(functionArn, emitter.filterDims(emitter.dims(functionArn)))
+	emitter.scheduler.AddGroupedCallback(functionArn, function.invocations)
+	emitter.scheduler.AddGroupedCallback(functionArn, function.reports)
+	emitter.scheduler.AddGroupedCallback(functionArn, function.outcomes)
+	emitter.scheduler.AddGroupedCallback(functionArn, function.custom)
+
+	return function
+}
@@ -0,0 +1,95 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const functionInit = "lambda.function.init"
+const functionInitDuration = "lambda.function.init.duration"
+
+// initPhase reports the initialization of the runtime from platform.initStart, platform.initRuntimeDone and platform.initReport,
+// unlike lambda.function.initialization.latency, it doesn't include the start of the extension or the idle time before the first invocation
+type initPhase struct {
+	mu sync.Mutex
+
+	initType string
+	start    time.Time
+	reported bool
+}
+
+func (emitter *MetricEmitter) initStarted(event telemetry.Event) {
+	record, err := event.PlatformRecord()
+	if err != nil {
+		log.Printf("unknown format of a %v record: %v\n", event.Type, err)
+		return
+	}
+
+	emitter.initPhase.mu.Lock()
+	defer emitter.initPhase.mu.Unlock()
+
+	emitter.initPhase.initType = record.InitializationType
+	emitter.initPhase.start = event.Time
+	emitter.initPhase.reported = false
+}
+
+// initDone takes the duration from the start of the phase, or from platform.initReport if the start is unknown
+func (emitter *MetricEmitter) initDone(event telemetry.Event) {
+	record, err := event.PlatformRecord()
+	if err != nil {
+		log.Printf("unknown format of a %v record: %v\n", event.Type, err)
+		return
+	}
+
+	emitter.initPhase.mu.Lock()
+	defer emitter.initPhase.mu.Unlock()
+
+	phase := &emitter.initPhase
+	if phase.reported {
+		return
+	}
+
+	var duration float64
+	switch {
+	case event.Type == telemetry.PlatformInitReport:
+		duration = record.Metrics.DurationMs
+	case !phase.start.IsZero():
+		duration = float64(event.Time.Sub(phase.start)) / float64(time.Millisecond)
+	default:
+		// the duration is taken from platform.initReport which follows
+		return
+	}
+
+	initType := record.InitializationType
+	if initType == "" {
+		initType = phase.initType
+	}
+	if initType == "" {
+		initType = os.Getenv(initializationTypeEnv)
+	}
+
+	dims := map[string]string{dimInitType: initType, dimStatus: record.Status}
+	emitter.environmentMetrics.add(
+		sfxclient.Counter(functionInit, dims, 1),
+		sfxclient.GaugeF(functionInitDuration, dims, duration),
+	)
+	phase.reported = true
+}
@@ -0,0 +1,78 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"sync"
+	"time"
+)
+
+const environmentSequence = "lambda.function.environment.sequence"
+const environmentInterArrival = "lambda.function.environment.inter_arrival"
+const environmentInvocations = "lambda.function.environment.invocations"
+
+// invocationSequence tracks how the environment is reused, the sequence number of the latest invocation
+// and the time since the previous invocation (in milliseconds), aggregated between reports
+type invocationSequence struct {
+	mu sync.Mutex
+
+	percentiles []float64
+
+	sequence     int64
+	previous     time.Time
+	interArrival distribution
+}
+
+func newInvocationSequence(percentiles []float64) *invocationSequence {
+	return &invocationSequence{percentiles: percentiles}
+}
+
+func (ei *invocationSequence) invoked(now time.Time) {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+
+	ei.sequence++
+	if !ei.previous.IsZero() {
+		ei.interArrival.add(float64(now.Sub(ei.previous)) / float64(time.Millisecond))
+	}
+	ei.previous = now
+}
+
+// served is the number of invocations handled by the environment
+func (ei *invocationSequence) served() *datapoint.Datapoint {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+
+	return sfxclient.Gauge(environmentInvocations, nil, ei.sequence)
+}
+
+func (ei *invocationSequence) Datapoints() []*datapoint.Datapoint {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+
+	if ei.sequence == 0 {
+		return nil
+	}
+
+	dps := []*datapoint.Datapoint{sfxclient.Gauge(environmentSequence, nil, ei.sequence)}
+	if ei.interArrival.count > 0 {
+		dps = append(dps, ei.interArrival.datapoints(environmentInterArrival, ei.percentiles)...)
+		ei.interArrival = distribution{}
+	}
+
+	return dps
+}
@@ -20,26 +20,36 @@ import (
 )
 
 const invocations = "lambda.function.invocation"
+const coldStarts = "lambda.function.cold_start"
 
 type invocationsCounter struct {
 	invocations int64
+	coldStarts  int64
+
+	// when set, the invocations are split into two series by the cold_start dimension
+	coldStartDimension bool
 }
 
-func (ic *invocationsCounter) invoked() {
+func (ic *invocationsCounter) invoked(coldStart bool) {
 	ic.invocations++
+	if coldStart {
+		ic.coldStarts++
+	}
 }
 
-func (ic *invocationsCounter) counter() *datapoint.Datapoint {
-	defer func() { ic.invocations = 0 }()
-	return sfxclient.Counter(
-		invocations,
-		nil,
-		ic.invocations,
-	)
+func (ic *invocationsCounter) counter() []*datapoint.Datapoint {
+	if !ic.coldStartDimension {
+		return []*datapoint.Datapoint{sfxclient.Counter(invocations, nil, ic.invocations)}
+	}
+	return []*datapoint.Datapoint{
+		sfxclient.Counter(invocations, map[string]string{dimColdStart: "true"}, ic.coldStarts),
+		sfxclient.Counter(invocations, map[string]string{dimColdStart: "false"}, ic.invocations-ic.coldStarts),
+	}
 }
 
 func (ic *invocationsCounter) Datapoints() []*datapoint.Datapoint {
-	return []*datapoint.Datapoint{
-		ic.counter(),
-	}
+	defer func() { ic.invocations, ic.coldStarts = 0, 0 }()
+	return append(ic.counter(),
+		sfxclient.Counter(coldStarts, nil, ic.coldStarts),
+	)
 }
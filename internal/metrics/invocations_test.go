@@ -0,0 +1,84 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestColdStartDimension(t *testing.T) {
+	counter := &invocationsCounter{coldStartDimension: true}
+	counter.invoked(true)
+	counter.invoked(false)
+	counter.invoked(false)
+
+	found := map[string]string{}
+	for _, dp := range counter.Datapoints() {
+		found[dp.Metric+":"+dp.Dimensions[dimColdStart]] = dp.Value.String()
+	}
+
+	expected := map[string]string{
+		invocations + ":true":  "1",
+		invocations + ":false": "2",
+		coldStarts + ":":       "1",
+	}
+	for key, value := range expected {
+		if found[key] != value {
+			t.Errorf("Expected `%v` of %v, got `%v`", value, key, found[key])
+		}
+	}
+
+	for _, dp := range counter.Datapoints() {
+		if dp.Value.String() != "0" {
+			t.Errorf("Expected the counters to be reset, got %v", dp)
+		}
+	}
+}
+
+func TestInvocationSequence(t *testing.T) {
+	sequence := newInvocationSequence([]float64{50})
+
+	if dps := sequence.Datapoints(); len(dps) != 0 {
+		t.Errorf("Expected no datapoints before the first invocation, got %v", dps)
+	}
+
+	t0 := time.Now()
+	for _, offset := range []time.Duration{0, 100 * time.Millisecond, 400 * time.Millisecond} {
+		sequence.invoked(t0.Add(offset))
+	}
+
+	found := map[string]string{}
+	for _, dp := range sequence.Datapoints() {
+		found[dp.Metric] = dp.Value.String()
+	}
+
+	expected := map[string]string{
+		environmentSequence:                "3",
+		environmentInterArrival + ".count": "2",
+		environmentInterArrival + ".min":   "100",
+		environmentInterArrival + ".max":   "300",
+		environmentInterArrival + ".p50":   "100",
+	}
+	for metric, value := range expected {
+		if found[metric] != value {
+			t.Errorf("Expected `%v` of %v, got `%v`", value, metric, found[metric])
+		}
+	}
+
+	if served := sequence.served(); served.Value.String() != "3" {
+		t.Errorf("Expected `%v`, got `%v`", 3, served.Value)
+	}
+}
@@ -16,17 +16,27 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/signalfx/golib/v3/trace"
 	"github.com/splunk/lambda-extension/internal/config"
+	"github.com/splunk/lambda-extension/internal/exporter"
+	"github.com/splunk/lambda-extension/internal/extensionapi"
 	"github.com/splunk/lambda-extension/internal/shutdown"
+	"github.com/splunk/lambda-extension/internal/telemetry"
 	"github.com/splunk/lambda-extension/internal/util"
 	"log"
 	"os"
+	"sync"
+	"time"
 )
 
 const awsExecutionEnv = "AWS_EXECUTION_ENV"
+const initializationTypeEnv = "AWS_LAMBDA_INITIALIZATION_TYPE"
+const provisionedConcurrency = "provisioned-concurrency"
+const snapStart = "snap-start"
 
 type MetricEmitter struct {
 	config    *config.Configuration
@@ -36,62 +46,153 @@ type MetricEmitter struct {
 	functionName    string
 	functionVersion string
 
-	arnToCounter map[string]*invocationsCounter
+	// guards the maps below, telemetry is handled on the listener's goroutines
+	mu sync.Mutex
+
+	arnToFunction map[string]*functionMetrics
+	requests      map[string]*pendingRequest
+	doneEarly     map[string]telemetry.Event // runtime done before the INVOKE event was handled
+	reportedEarly map[string]struct{}        // reported before their runtime done
+	currentArn    string
+	customMetrics *adhocCollector
+	spans         []*trace.Span
 
 	ctx context.Context
 
 	sendOutTicker util.Ticker
+	sender        *sender
+	tracing       bool
+	subscribed    bool
+
+	activity           activityTracker
+	invocationSequence *invocationSequence
+	initPhase          initPhase
 
 	environmentMetrics
 }
 
-func New() *MetricEmitter {
-	configuration := config.New()
+// New takes the configuration built at init, so the tokens aren't fetched again
+func New(configuration *config.Configuration) *MetricEmitter {
+	// every destination is bounded by its own timeout, REPORTING_TIMEOUT is only their default
+	return newEmitter(*configuration, exporter.New(configuration), exporter.NewTraceSink(configuration), 0)
+}
+
+// NewWithSink creates an emitter sending the datapoints to the given sink instead of the configured destinations,
+// the spans are sent to it as well if it's a trace.Sink and the traces are enabled.
+func NewWithSink(configuration *config.Configuration, sink sfxclient.Sink) *MetricEmitter {
+	var traceSink trace.Sink
+	if s, ok := sink.(trace.Sink); ok && configuration.TracesEnabled {
+		traceSink = s
+	}
+
+	return newEmitter(*configuration, sink, traceSink, configuration.ReportingTimeout)
+}
 
+// newEmitter takes a nil traceSink when the traces are disabled, see newSender for the sendTimeout
+func newEmitter(configuration config.Configuration, sink sfxclient.Sink, traceSink trace.Sink, sendTimeout time.Duration) *MetricEmitter {
 	scheduler := sfxclient.NewScheduler()
-	scheduler.Sink.(*sfxclient.HTTPSink).DatapointEndpoint = configuration.SplunkMetricsUrl
-	scheduler.Sink.(*sfxclient.HTTPSink).AuthToken = configuration.SplunkToken
-	scheduler.Sink.(*sfxclient.HTTPSink).Client.Timeout = configuration.ReportingTimeout
+	scheduler.Sink = sink
 	scheduler.ReportingTimeout(configuration.ReportingTimeout)
 
 	emitter := &MetricEmitter{
 		config:    &configuration,
 		scheduler: scheduler,
 
-		arnToCounter: make(map[string]*invocationsCounter),
+		arnToFunction: make(map[string]*functionMetrics),
+		requests:      make(map[string]*pendingRequest),
+		doneEarly:     make(map[string]telemetry.Event),
+		reportedEarly: make(map[string]struct{}),
+		customMetrics: newCustomCollector(),
+
+		invocationSequence: newInvocationSequence(configuration.Percentiles),
 
 		ctx: context.Background(),
 
 		sendOutTicker: util.NewTicker(configuration),
+		tracing:       traceSink != nil,
 	}
 
 	if configuration.HttpTracing {
 		emitter.ctx = util.WithClientTracing(emitter.ctx)
 	}
 
+	emitter.sender = newSender(emitter.ctx, sink, traceSink, sendTimeout)
+
 	scheduler.AddCallback(&emitter.environmentMetrics)
+	scheduler.AddCallback(emitter.customMetrics)
+	scheduler.AddCallback(emitter.invocationSequence)
+	if collector, ok := scheduler.Sink.(sfxclient.Collector); ok {
+		scheduler.AddCallback(collector)
+	}
 
 	emitter.environmentMetrics.markStart()
+	emitter.activity.started(emitter.environmentMetrics.startTime)
 
 	return emitter
 }
 
-func (emitter *MetricEmitter) Invoked(functionArn string, failFast bool) shutdown.Condition {
-	if counter, found := emitter.arnToCounter[functionArn]; found {
-		counter.invoked()
-	} else {
-		emitter.registerCounter(functionArn)
+// Invoked doesn't wait for the datapoints to be sent, see Drain
+func (emitter *MetricEmitter) Invoked(event *extensionapi.Event) {
+	functionArn := event.InvokedFunctionArn
+
+	now := time.Now()
+	emitter.activity.thawed(now, event.RequestId, deadline(event))
+	emitter.invocationSequence.invoked(now)
+
+	emitter.mu.Lock()
+	function, found := emitter.arnToFunction[functionArn]
+	if !found {
+		function = emitter.registerFunction(functionArn)
+	}
+	function.invocations.invoked(emitter.isColdStart())
+	emitter.currentArn = functionArn
+	if emitter.config.TelemetryEnabled {
+		emitter.trackRequest(event.RequestId, functionArn, now)
+	}
+	runtimeDone, doneEarly := emitter.doneEarly[event.RequestId]
+	delete(emitter.doneEarly, event.RequestId)
+	emitter.mu.Unlock()
+
+	if doneEarly {
+		emitter.runtimeDone(runtimeDone)
 	}
 
 	if !emitter.started {
-		emitter.markFirstInvocation()
+		emitter.markFirstInvocation(emitter.awaitsRestore())
 		dims := emitter.dims(functionArn)
 		delete(dims, dimQualifier) // the env metrics are only related to the function version
-		emitter.scheduler.DefaultDimensions(dims)
+		emitter.scheduler.DefaultDimensions(emitter.filterDims(dims))
 		emitter.started = true
 	}
 
-	return emitter.tryToSendOut(failFast)
+	emitter.tryToSendOut(deadline(event))
+}
+
+// Drain waits for the datapoints to be sent, it has to be called before asking for the next event,
+// since the environment can be frozen right after that. It returns right away when nothing is being sent,
+// the reports are bounded by the deadline of the invocation minus the margin.
+func (emitter *MetricEmitter) Drain(failFast bool) shutdown.Condition {
+	err := emitter.sender.drain()
+	emitter.activity.idle(time.Now())
+
+	if err == nil {
+		return nil
+	}
+	message := fmt.Sprintf("failed to send metrics: %v", err)
+	if shouldFailFast(err, failFast) {
+		return shutdown.Metric(message)
+	} else {
+		log.Println(message)
+		return nil
+	}
+}
+
+// deadline of the invocation, zero when it's unknown
+func deadline(event *extensionapi.Event) time.Time {
+	if event.DeadlineMs <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, event.DeadlineMs*int64(time.Millisecond))
 }
 
 func (emitter *MetricEmitter) SetFunction(functionName, functionVersion string) {
@@ -99,26 +200,50 @@ func (emitter *MetricEmitter) SetFunction(functionName, functionVersion string)
 	emitter.functionVersion = functionVersion
 }
 
+// isColdStart tells whether the invocation had to wait for the environment to initialize,
+// it's never the case with the provisioned concurrency
+func (emitter *MetricEmitter) isColdStart() bool {
+	return !emitter.started && os.Getenv(initializationTypeEnv) != provisionedConcurrency
+}
+
+// TelemetrySubscribed is called once the platform events are subscribed, before the first invocation
+func (emitter *MetricEmitter) TelemetrySubscribed() {
+	emitter.subscribed = true
+}
+
+// awaitsRestore tells whether a restore from a snapshot is going to be reported by the Telemetry API
+func (emitter *MetricEmitter) awaitsRestore() bool {
+	return emitter.subscribed && os.Getenv(initializationTypeEnv) == snapStart
+}
+
+// AddCollector registers a collector whose datapoints get the dimensions of the environment.
+func (emitter *MetricEmitter) AddCollector(collector sfxclient.Collector) {
+	emitter.scheduler.AddCallback(collector)
+}
+
 func (emitter *MetricEmitter) Shutdown(condition shutdown.Condition) {
 	if !emitter.started {
 		log.Printf("closing emitter that wasn't started")
 	}
 
 	emitter.environmentMetrics.markEnd(condition.Reason())
+	emitter.environmentMetrics.markActivity(emitter.activity.times(emitter.environmentMetrics.endTime))
+	emitter.environmentMetrics.add(emitter.invocationSequence.served())
 
-	if err := emitter.scheduler.ReportOnce(emitter.ctx); err != nil {
+	emitter.sender.enqueue(emitter.scheduler.CollectDatapoints(), emitter.takeSpans(), time.Time{})
+	if err := emitter.sender.drain(); err != nil {
 		log.SetOutput(os.Stderr)
 		log.Printf("failed to report metrics on shutdown: %v\n", err)
 	}
 }
 
-func (emitter MetricEmitter) buildAWSUniqueId(functionArn arn.ARN) string {
+func (emitter *MetricEmitter) buildAWSUniqueId(functionArn arn.ARN) string {
 	return fmt.Sprintf("lambda_%s:%s_%s_%s",
 		emitter.functionName, emitter.functionVersion,
 		functionArn.Region, functionArn.AccountID)
 }
 
-func (emitter MetricEmitter) arnWithVersion(parsedArn arn.ARN) string {
+func (emitter *MetricEmitter) arnWithVersion(parsedArn arn.ARN) string {
 	resource := resourceFromArn(parsedArn)
 
 	resource.qualifier = emitter.functionVersion
@@ -127,30 +252,32 @@ func (emitter MetricEmitter) arnWithVersion(parsedArn arn.ARN) string {
 	return parsedArn.String()
 }
 
-func (emitter *MetricEmitter) tryToSendOut(failFast bool) shutdown.Condition {
+// tryToSendOut never lets the report run past the deadline of the invocation (minus the configured margin).
+// When there's no time left, nothing is collected (and the ticker doesn't tick), so the datapoints are kept
+// until the next report. A report cut short by the deadline is spooled by the destinations, see exporter.New.
+func (emitter *MetricEmitter) tryToSendOut(deadline time.Time) {
+	cutoff := time.Time{}
+	if !deadline.IsZero() {
+		cutoff = deadline.Add(-emitter.config.DeadlineMargin)
+		if !time.Now().Before(cutoff) {
+			log.Printf("not sending metrics, the invocation is %v from its deadline\n", time.Until(deadline))
+			return
+		}
+	}
+
 	if !emitter.sendOutTicker.Tick() {
-		return nil
+		return
 	}
+
 	log.Println("sending metrics")
-	err := emitter.scheduler.ReportOnce(emitter.ctx)
-	if err == nil {
-		return nil
-	}
-	message := fmt.Sprintf("failed to send metrics: %v", err)
-	if failFast {
-		return shutdown.Metric(message)
-	} else {
-		log.Println(message)
-		return nil
-	}
+	emitter.sender.enqueue(emitter.scheduler.CollectDatapoints(), emitter.takeSpans(), cutoff)
 }
 
-func (emitter *MetricEmitter) registerCounter(functionArn string) {
-	counter := &invocationsCounter{}
-	counter.invoked()
-
-	emitter.arnToCounter[functionArn] = counter
-
-	emitter.scheduler.GroupedDefaultDimensions(functionArn, emitter.dims(functionArn))
-	emitter.scheduler.AddGroupedCallback(functionArn, counter)
+// every destination decides on its own, failFast only applies to errors not coming from destinations
+func shouldFailFast(err error, failFast bool) bool {
+	var destinationErrors exporter.Errors
+	if errors.As(err, &destinationErrors) {
+		return destinationErrors.FailFast()
+	}
+	return failFast
 }
@@ -0,0 +1,157 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/splunk/lambda-extension/internal/extensionapi"
+	"testing"
+	"time"
+)
+
+type deadlineSink struct {
+	deadlines []time.Time
+	points    []*datapoint.Datapoint
+}
+
+func (s *deadlineSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	deadline, _ := ctx.Deadline()
+	s.deadlines = append(s.deadlines, deadline)
+	s.points = append(s.points, points...)
+	return nil
+}
+
+func TestDeadlineAwareSending(t *testing.T) {
+	t.Setenv("FAST_INGEST", "true")
+	t.Setenv("REPORTING_TIMEOUT", "5")
+	t.Setenv("SPLUNK_DEADLINE_MARGIN_MS", "100")
+
+	sink := &deadlineSink{}
+	emitter := NewWithSink(testConfiguration(), sink)
+	emitter.SetFunction("helloworld", "42")
+
+	invoke := func(requestId string, deadline time.Time) {
+		event := &extensionapi.Event{RequestId: requestId, InvokedFunctionArn: testArn}
+		if !deadline.IsZero() {
+			event.DeadlineMs = deadline.UnixNano() / int64(time.Millisecond)
+		}
+		emitter.Invoked(event)
+		if sc := emitter.Drain(false); sc != nil {
+			t.Fatalf("unexpected shutdown condition: %v", sc.Message())
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	invoke("req-1", deadline)
+
+	if len(sink.deadlines) != 1 {
+		t.Fatalf("Expected `%v` reports, got `%v`", 1, len(sink.deadlines))
+	}
+	if cutoff := deadline.Add(-100 * time.Millisecond); sink.deadlines[0].After(cutoff) {
+		t.Errorf("Expected the report to end before `%v`, got `%v`", cutoff, sink.deadlines[0])
+	}
+
+	invoke("req-2", time.Now().Add(50*time.Millisecond))
+
+	if len(sink.deadlines) != 1 {
+		t.Errorf("Expected no report close to the deadline, got `%v` reports", len(sink.deadlines))
+	}
+
+	invoke("req-3", time.Time{})
+
+	invocationsSent := int64(0)
+	for _, dp := range sink.points {
+		if dp.Metric == invocations {
+			invocationsSent += dp.Value.(datapoint.IntValue).Int()
+		}
+	}
+	if len(sink.deadlines) != 2 || invocationsSent != 3 {
+		t.Errorf("Expected all the invocations to be sent in `%v` reports, got `%v` in `%v`", 2, invocationsSent, len(sink.deadlines))
+	}
+}
+
+func TestSkippedReportKeepsTheTick(t *testing.T) {
+	t.Setenv("FAST_INGEST", "false")
+	t.Setenv("REPORTING_RATE", "60")
+	t.Setenv("SPLUNK_DEADLINE_MARGIN_MS", "100")
+
+	sink := &deadlineSink{}
+	emitter := NewWithSink(testConfiguration(), sink)
+	emitter.SetFunction("helloworld", "42")
+
+	nearDeadline := &extensionapi.Event{RequestId: "req-1", InvokedFunctionArn: testArn,
+		DeadlineMs: time.Now().Add(50*time.Millisecond).UnixNano() / int64(time.Millisecond)}
+	emitter.Invoked(nearDeadline)
+	emitter.Drain(false)
+
+	if len(sink.deadlines) != 0 {
+		t.Fatalf("Expected no report close to the deadline, got `%v` reports", len(sink.deadlines))
+	}
+
+	emitter.Invoked(&extensionapi.Event{RequestId: "req-2", InvokedFunctionArn: testArn})
+	emitter.Drain(false)
+
+	if len(sink.deadlines) != 1 {
+		t.Errorf("Expected the skipped report to be sent on the next invocation, got `%v` reports", len(sink.deadlines))
+	}
+}
+
+type slowSink struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowSink) AddDatapoints(ctx context.Context, points []*datapoint.Datapoint) error {
+	s.started <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func TestSendingWhileTheFunctionRuns(t *testing.T) {
+	t.Setenv("FAST_INGEST", "true")
+
+	sink := &slowSink{started: make(chan struct{}, 1), release: make(chan struct{})}
+	emitter := NewWithSink(testConfiguration(), sink)
+	emitter.SetFunction("helloworld", "42")
+
+	event := &extensionapi.Event{RequestId: "req-1", InvokedFunctionArn: testArn,
+		DeadlineMs: time.Now().Add(5*time.Second).UnixNano() / int64(time.Millisecond)}
+	emitter.Invoked(event)
+
+	select {
+	case <-sink.started:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the report to be sent while the function runs")
+	}
+
+	// the drain only waits for the report, not for the telemetry of the invocation
+	time.AfterFunc(50*time.Millisecond, func() { close(sink.release) })
+
+	start := time.Now()
+	if sc := emitter.Drain(false); sc != nil {
+		t.Errorf("Expected no shutdown condition, got `%v`", sc.Message())
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected the drain to wait for the report, it took `%v`", elapsed)
+	}
+
+	// nothing is being sent
+	start = time.Now()
+	emitter.Drain(false)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected the drain to return right away, it took `%v`", elapsed)
+	}
+}
@@ -17,6 +17,7 @@ package metrics
 import (
 	"github.com/signalfx/golib/v3/datapoint"
 	"github.com/signalfx/golib/v3/sfxclient"
+	"sync"
 	"time"
 )
 
@@ -24,49 +25,120 @@ const environmentStart = "lambda.function.initialization"
 const environmentStartDuration = "lambda.function.initialization.latency"
 const environmentShutdown = "lambda.function.shutdown"
 const environmentLifetime = "lambda.function.lifetime"
+const functionRestore = "lambda.function.restore"
+const functionRestoreLatency = "lambda.function.restore.latency"
 
 type environmentMetrics struct {
+	// the environment can be restored from a snapshot, which is reported by the Telemetry API on another goroutine
+	mu sync.Mutex
+
 	adhocDps []*datapoint.Datapoint
 
 	startTime       time.Time
 	firstInvocation time.Time
 	endTime         time.Time
+	restoreStart    time.Time
+	// the restore is usually reported after the first invocation, its latency waits for it
+	restored    bool
+	latencyHeld bool
 }
 
 func (em *environmentMetrics) markStart() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	em.startTime = time.Now()
 	em.adhocDps = append(em.adhocDps, em.startCounter())
 }
 
-func (em *environmentMetrics) markFirstInvocation() {
+// markFirstInvocation holds the latency of an environment restored from a snapshot until the restore is reported
+func (em *environmentMetrics) markFirstInvocation(awaitRestore bool) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	em.firstInvocation = time.Now()
+	if awaitRestore && !em.restored {
+		em.latencyHeld = true
+		return
+	}
 	em.adhocDps = append(em.adhocDps, em.startLatency())
 }
 
+// markRestore moves the start of the environment to its restore from a snapshot,
+// the process itself was started before the snapshot was taken
+func (em *environmentMetrics) markRestore(restoreStart time.Time) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.startTime = restoreStart
+	em.restoreStart = restoreStart
+	em.restored = true
+	if em.latencyHeld {
+		em.adhocDps = append(em.adhocDps, em.startLatency())
+		em.latencyHeld = false
+	}
+}
+
+// markRestored reports the restore, with its latency when its start is known
+func (em *environmentMetrics) markRestored(restoreDone time.Time, status string) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.adhocDps = append(em.adhocDps, sfxclient.Counter(functionRestore, map[string]string{dimStatus: status}, 1))
+	if !em.restoreStart.IsZero() {
+		dur := restoreDone.Sub(em.restoreStart)
+		em.adhocDps = append(em.adhocDps, sfxclient.Gauge(functionRestoreLatency, nil, dur.Milliseconds()))
+		em.restoreStart = time.Time{}
+	}
+}
+
 func (em *environmentMetrics) markEnd(cause string) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	em.endTime = time.Now()
 	em.adhocDps = append(em.adhocDps, em.endCounter(cause), em.envDuration())
+	if em.latencyHeld {
+		// the restore was never reported, the latency is measured from the start of the process
+		em.adhocDps = append(em.adhocDps, em.startLatency())
+		em.latencyHeld = false
+	}
+}
+
+func (em *environmentMetrics) markActivity(active, frozen time.Duration) {
+	em.add(sfxclient.Gauge(environmentActiveTime, nil, active.Milliseconds()),
+		sfxclient.Gauge(environmentFrozenTime, nil, frozen.Milliseconds()))
 }
 
-func (em environmentMetrics) startCounter() *datapoint.Datapoint {
+func (em *environmentMetrics) add(dps ...*datapoint.Datapoint) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.adhocDps = append(em.adhocDps, dps...)
+}
+
+func (em *environmentMetrics) startCounter() *datapoint.Datapoint {
 	return sfxclient.Counter(environmentStart, nil, 1)
 }
 
-func (em environmentMetrics) startLatency() *datapoint.Datapoint {
+func (em *environmentMetrics) startLatency() *datapoint.Datapoint {
 	dur := em.firstInvocation.Sub(em.startTime)
 	return sfxclient.Gauge(environmentStartDuration, nil, dur.Milliseconds())
 }
 
-func (em environmentMetrics) endCounter(cause string) *datapoint.Datapoint {
+func (em *environmentMetrics) endCounter(cause string) *datapoint.Datapoint {
 	return sfxclient.Counter(environmentShutdown, map[string]string{dimShutdownCause: cause}, 1)
 }
 
-func (em environmentMetrics) envDuration() *datapoint.Datapoint {
+func (em *environmentMetrics) envDuration() *datapoint.Datapoint {
 	dur := em.endTime.Sub(em.startTime)
 	return sfxclient.Gauge(environmentLifetime, nil, dur.Milliseconds())
 }
 
 func (em *environmentMetrics) Datapoints() []*datapoint.Datapoint {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
 	defer func() { em.adhocDps = nil }()
 	return em.adhocDps
 }
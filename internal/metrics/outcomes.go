@@ -0,0 +1,56 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"sync"
+)
+
+const functionErrors = "lambda.function.errors"
+const functionTimeouts = "lambda.function.timeouts"
+
+// outcomesCounter counts the invocations which didn't succeed, based on the status reported by the Telemetry API.
+// A runtime failure (e.g. a crash or running out of memory) is counted as an error.
+type outcomesCounter struct {
+	mu       sync.Mutex
+	errors   int64
+	timeouts int64
+}
+
+func (oc *outcomesCounter) completed(status string) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	switch status {
+	case telemetry.StatusError, telemetry.StatusFailure:
+		oc.errors++
+	case telemetry.StatusTimeout:
+		oc.timeouts++
+	}
+}
+
+func (oc *outcomesCounter) Datapoints() []*datapoint.Datapoint {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+
+	defer func() { oc.errors, oc.timeouts = 0, 0 }()
+	return []*datapoint.Datapoint{
+		sfxclient.Counter(functionErrors, nil, oc.errors),
+		sfxclient.Counter(functionTimeouts, nil, oc.timeouts),
+	}
+}
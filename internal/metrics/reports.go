@@ -0,0 +1,133 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const functionDuration = "lambda.function.duration"
+const functionBilledDuration = "lambda.function.billed_duration"
+const functionMaxMemoryUsed = "lambda.function.max_memory_used"
+
+// the percentiles are computed from a uniform sample of the values when there are more of them
+const maxDistributionSamples = 1000
+
+// reportsCollector aggregates platform.report events between reports,
+// the durations into distributions and the memory into its maximum
+type reportsCollector struct {
+	mu sync.Mutex
+
+	percentiles []float64
+
+	duration       distribution
+	billedDuration distribution
+	maxMemoryUsed  int64
+}
+
+func newReportsCollector(percentiles []float64) *reportsCollector {
+	return &reportsCollector{percentiles: percentiles}
+}
+
+func (rc *reportsCollector) reported(metrics telemetry.ReportMetrics) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.duration.add(metrics.DurationMs)
+	rc.billedDuration.add(metrics.BilledDurationMs)
+	if metrics.MaxMemoryUsedMB > rc.maxMemoryUsed {
+		rc.maxMemoryUsed = metrics.MaxMemoryUsedMB
+	}
+}
+
+func (rc *reportsCollector) Datapoints() []*datapoint.Datapoint {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if rc.duration.count == 0 {
+		return nil
+	}
+
+	dps := append(rc.duration.datapoints(functionDuration, rc.percentiles),
+		rc.billedDuration.datapoints(functionBilledDuration, rc.percentiles)...)
+	dps = append(dps, sfxclient.Gauge(functionMaxMemoryUsed, nil, rc.maxMemoryUsed))
+
+	rc.duration = distribution{}
+	rc.billedDuration = distribution{}
+	rc.maxMemoryUsed = 0
+
+	return dps
+}
+
+// distribution keeps the count, sum, min and max of the values and a sample for the percentiles
+type distribution struct {
+	count   int64
+	sum     float64
+	min     float64
+	max     float64
+	samples []float64
+}
+
+func (d *distribution) add(value float64) {
+	d.count++
+	d.sum += value
+
+	if d.count == 1 || value < d.min {
+		d.min = value
+	}
+	if d.count == 1 || value > d.max {
+		d.max = value
+	}
+
+	// reservoir sampling, every value has the same chance to be kept
+	if len(d.samples) < maxDistributionSamples {
+		d.samples = append(d.samples, value)
+	} else if i := rand.Int63n(d.count); i < maxDistributionSamples {
+		d.samples[i] = value
+	}
+}
+
+func (d *distribution) datapoints(metric string, percentiles []float64) []*datapoint.Datapoint {
+	dps := []*datapoint.Datapoint{
+		sfxclient.Counter(metric+".count", nil, d.count),
+		datapoint.New(metric+".sum", nil, datapoint.NewFloatValue(d.sum), datapoint.Count, time.Time{}),
+		sfxclient.GaugeF(metric+".min", nil, d.min),
+		sfxclient.GaugeF(metric+".max", nil, d.max),
+	}
+
+	sort.Float64s(d.samples)
+	for _, p := range percentiles {
+		dps = append(dps, sfxclient.GaugeF(metric+".p"+strconv.FormatFloat(p, 'f', -1, 64), nil, d.percentile(p)))
+	}
+
+	return dps
+}
+
+// percentile uses the nearest-rank method on the sorted samples
+func (d *distribution) percentile(p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(d.samples))))
+	if rank < 1 {
+		rank = 1
+	}
+	return d.samples[rank-1]
+}
@@ -0,0 +1,63 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	d := distribution{}
+	for i := 100; i >= 1; i-- {
+		d.add(float64(i))
+	}
+
+	found := map[string]string{}
+	for _, dp := range d.datapoints("latency", []float64{50, 90, 99.9}) {
+		found[dp.Metric] = dp.Value.String()
+	}
+
+	expected := map[string]string{
+		"latency.count": "100",
+		"latency.sum":   "5050",
+		"latency.min":   "1",
+		"latency.max":   "100",
+		"latency.p50":   "50",
+		"latency.p90":   "90",
+		"latency.p99.9": "100",
+	}
+	for metric, value := range expected {
+		if found[metric] != value {
+			t.Errorf("Expected `%v` of %v, got `%v`", value, metric, found[metric])
+		}
+	}
+}
+
+func TestDistributionSamples(t *testing.T) {
+	d := distribution{}
+	for i := 0; i < 10*maxDistributionSamples; i++ {
+		d.add(1)
+	}
+
+	if len(d.samples) != maxDistributionSamples || d.count != 10*maxDistributionSamples {
+		t.Errorf("Expected `%v` samples of `%v` values, got `%v` of `%v`", maxDistributionSamples, 10*maxDistributionSamples, len(d.samples), d.count)
+	}
+}
+
+func TestNoReports(t *testing.T) {
+	if dps := newReportsCollector([]float64{50}).Datapoints(); len(dps) != 0 {
+		t.Errorf("Expected no datapoints, got %v", dps)
+	}
+}
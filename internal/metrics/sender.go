@@ -0,0 +1,137 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/signalfx/golib/v3/trace"
+	"github.com/splunk/lambda-extension/internal/exporter"
+	"log"
+	"sync"
+	"time"
+)
+
+// at most this many reports can wait for the sender, the newer ones are dropped
+const sendQueueSize = 16
+
+type report struct {
+	points   []*datapoint.Datapoint
+	spans    []*trace.Span
+	deadline time.Time
+}
+
+// sender sends the reports on its own goroutine, so collecting the datapoints doesn't wait for the network.
+// The queue has to be drained before the environment can be frozen, otherwise a report could be stuck in the middle of sending.
+type sender struct {
+	ctx       context.Context
+	sink      sfxclient.Sink
+	traceSink trace.Sink
+	timeout   time.Duration
+
+	queue   chan report
+	pending sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// newSender takes a nil traceSink when the traces are disabled,
+// and a zero timeout when the sinks are bounded by their own timeouts
+func newSender(ctx context.Context, sink sfxclient.Sink, traceSink trace.Sink, timeout time.Duration) *sender {
+	s := &sender{
+		ctx:       ctx,
+		sink:      sink,
+		traceSink: traceSink,
+		timeout:   timeout,
+		queue:     make(chan report, sendQueueSize),
+	}
+	go s.run()
+	return s
+}
+
+// enqueue doesn't block, the report is dropped when the queue is full
+func (s *sender) enqueue(points []*datapoint.Datapoint, spans []*trace.Span, deadline time.Time) {
+	if len(points) == 0 && len(spans) == 0 {
+		return
+	}
+
+	s.pending.Add(1)
+	select {
+	case s.queue <- report{points: points, spans: spans, deadline: deadline}:
+	default:
+		s.pending.Done()
+		log.Printf("dropping %v datapoints and %v spans, %v reports are already waiting to be sent\n", len(points), len(spans), sendQueueSize)
+	}
+}
+
+// drain waits for the queued reports to be sent and returns the first error of the datapoints since the last drain
+func (s *sender) drain() error {
+	s.pending.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.err
+	s.err = nil
+	return err
+}
+
+func (s *sender) run() {
+	for r := range s.queue {
+		s.send(r)
+		s.pending.Done()
+	}
+}
+
+func (s *sender) send(r report) {
+	ctx := s.ctx
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	if !r.deadline.IsZero() {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = exporter.WithInvocationDeadline(ctx, r.deadline)
+		defer cancelDeadline()
+	}
+
+	if len(r.points) > 0 {
+		s.failed(s.sink.AddDatapoints(ctx, r.points))
+	}
+	// the errors of the spans are only logged, failing fast is decided by the metrics destinations
+	if len(r.spans) > 0 && s.traceSink != nil {
+		if err := s.traceSink.AddSpans(ctx, r.spans); err != nil {
+			log.Printf("failed to send %v spans: %v\n", len(r.spans), err)
+		}
+	}
+}
+
+// failed keeps the first error until the next drain
+func (s *sender) failed(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err == nil {
+		s.err = err
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/sfxclient"
+	"github.com/signalfx/golib/v3/trace"
+	"testing"
+	"time"
+)
+
+type blockingSink struct {
+	release chan struct{}
+	sent    int
+}
+
+func (s *blockingSink) AddDatapoints(_ context.Context, _ []*datapoint.Datapoint) error {
+	<-s.release
+	s.sent++
+	return errors.New("rejected")
+}
+
+func TestSenderQueue(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	s := newSender(context.Background(), sink, nil, time.Second)
+
+	points := []*datapoint.Datapoint{sfxclient.Gauge("test", nil, 1)}
+
+	// the first one is taken by the sender, the rest fill the queue and the last one is dropped
+	for i := 0; i < sendQueueSize+2; i++ {
+		s.enqueue(points, nil, time.Time{})
+		if i == 0 {
+			for len(s.queue) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	s.enqueue(nil, nil, time.Time{})
+
+	close(sink.release)
+
+	if err := s.drain(); err == nil || err.Error() != "rejected" {
+		t.Errorf("Expected `%v`, got `%v`", "rejected", err)
+	}
+	if sink.sent != sendQueueSize+1 {
+		t.Errorf("Expected `%v` reports, got `%v`", sendQueueSize+1, sink.sent)
+	}
+	if err := s.drain(); err != nil {
+		t.Errorf("Expected the error to be reset, got `%v`", err)
+	}
+}
+
+func TestSenderWithoutTimeout(t *testing.T) {
+	sink := &deadlineSink{}
+	s := newSender(context.Background(), sink, nil, 0)
+
+	points := []*datapoint.Datapoint{sfxclient.Gauge("test", nil, 1)}
+	deadline := time.Now().Add(time.Minute)
+
+	s.enqueue(points, nil, time.Time{})
+	s.enqueue(points, nil, deadline)
+	if err := s.drain(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.deadlines) != 2 || !sink.deadlines[0].IsZero() || !sink.deadlines[1].Equal(deadline) {
+		t.Errorf("Expected only the deadline of the invocation, got `%v`", sink.deadlines)
+	}
+}
+
+type rejectingSpanSink struct {
+	spans int
+}
+
+func (s *rejectingSpanSink) AddSpans(_ context.Context, spans []*trace.Span) error {
+	s.spans += len(spans)
+	return errors.New("rejected")
+}
+
+func TestSenderSpanErrors(t *testing.T) {
+	traceSink := &rejectingSpanSink{}
+	s := newSender(context.Background(), &deadlineSink{}, traceSink, time.Second)
+
+	points := []*datapoint.Datapoint{sfxclient.Gauge("test", nil, 1)}
+	s.enqueue(points, []*trace.Span{{}}, time.Time{})
+
+	if err := s.drain(); err != nil {
+		t.Errorf("Expected the span errors to be kept apart from the datapoints, got `%v`", err)
+	}
+	if traceSink.spans != 1 {
+		t.Errorf("Expected `%v` spans, got `%v`", 1, traceSink.spans)
+	}
+}
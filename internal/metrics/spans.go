@@ -0,0 +1,96 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/signalfx/golib/v3/trace"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"log"
+	"time"
+)
+
+// at most this many spans wait for the next report, the newer ones are dropped
+const maxPendingSpans = 1000
+
+const spanKindServer = "SERVER"
+
+const tagRequestId = "aws_request_id"
+const tagError = "error"
+
+// recordSpan builds the span of an invocation, from the INVOKE event to platform.runtimeDone (or to the end reported
+// by platform.report when it comes first), so the functions without their own instrumentation still show up in the service map.
+// It's called with the mutex held.
+func (emitter *MetricEmitter) recordSpan(requestId string, request *pendingRequest, status string, end time.Time) {
+	if len(emitter.spans) >= maxPendingSpans {
+		log.Printf("dropping the span of %v, %v spans are already waiting to be sent\n", requestId, maxPendingSpans)
+		return
+	}
+
+	duration := end.Sub(request.invokedAt)
+	if duration < 0 {
+		duration = 0
+	}
+
+	tags := emitter.filterDims(emitter.dims(request.functionArn))
+	tags[tagRequestId] = requestId
+	tags[dimStatus] = status
+	if status != telemetry.StatusSuccess {
+		tags[tagError] = "true"
+	}
+
+	name := emitter.functionName
+	kind := spanKindServer
+	timestamp := request.invokedAt.UnixNano() / int64(time.Microsecond)
+	durationMicros := int64(duration / time.Microsecond)
+
+	emitter.spans = append(emitter.spans, &trace.Span{
+		TraceID:       randomId(16),
+		ID:            randomId(8),
+		Name:          &name,
+		Kind:          &kind,
+		Timestamp:     &timestamp,
+		Duration:      &durationMicros,
+		LocalEndpoint: &trace.Endpoint{ServiceName: &name},
+		Tags:          tags,
+	})
+}
+
+// reportedEnd of the function, the report is only sent once the extensions are done as well
+func reportedEnd(request *pendingRequest, record *telemetry.PlatformRecord, reportedAt time.Time) time.Time {
+	if record.Metrics.DurationMs <= 0 {
+		return reportedAt
+	}
+	return request.invokedAt.Add(time.Duration(record.Metrics.DurationMs * float64(time.Millisecond)))
+}
+
+func (emitter *MetricEmitter) takeSpans() []*trace.Span {
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+
+	spans := emitter.spans
+	emitter.spans = nil
+	return spans
+}
+
+// randomId is a hex encoded ID of the given number of bytes
+func randomId(size int) string {
+	id := make([]byte, size)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("can't generate a random ID: %v\n", err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,135 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/signalfx/golib/v3/trace"
+	"github.com/splunk/lambda-extension/internal/extensionapi"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"testing"
+	"time"
+)
+
+type spanSink struct {
+	spans []*trace.Span
+}
+
+func (s *spanSink) AddDatapoints(_ context.Context, _ []*datapoint.Datapoint) error {
+	return nil
+}
+
+func (s *spanSink) AddSpans(_ context.Context, spans []*trace.Span) error {
+	s.spans = append(s.spans, spans...)
+	return nil
+}
+
+func TestInvocationSpans(t *testing.T) {
+	t.Setenv("FAST_INGEST", "true")
+	t.Setenv("SPLUNK_TRACES_ENABLED", "true")
+
+	sink := &spanSink{}
+	emitter := NewWithSink(testConfiguration(), sink)
+	emitter.SetFunction("helloworld", "42")
+
+	invoked := time.Now()
+	for _, requestId := range []string{"req-1", "req-2"} {
+		emitter.Invoked(&extensionapi.Event{RequestId: requestId, InvokedFunctionArn: testArn})
+	}
+
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(invoked.Add(20*time.Millisecond), telemetry.PlatformRuntimeDone, map[string]interface{}{
+			"requestId": "req-1",
+			"status":    "success",
+		}),
+		platformEvent(invoked.Add(30*time.Millisecond), telemetry.PlatformRuntimeDone, map[string]interface{}{
+			"requestId": "req-2",
+			"status":    "error",
+		}),
+		platformEvent(invoked.Add(30*time.Millisecond), telemetry.PlatformRuntimeDone, map[string]interface{}{
+			"requestId": "unknown",
+			"status":    "success",
+		}),
+	})
+
+	emitter.Invoked(&extensionapi.Event{RequestId: "req-3", InvokedFunctionArn: testArn})
+	if sc := emitter.Drain(false); sc != nil {
+		t.Fatalf("unexpected shutdown condition: %v", sc.Message())
+	}
+
+	if len(sink.spans) != 2 {
+		t.Fatalf("Expected `%v` spans, got `%v`", 2, len(sink.spans))
+	}
+
+	first, second := sink.spans[0], sink.spans[1]
+	if *first.Name != "helloworld" || *first.LocalEndpoint.ServiceName != "helloworld" || *first.Kind != spanKindServer {
+		t.Errorf("Unexpected span: %v, %v, %v", *first.Name, *first.LocalEndpoint.ServiceName, *first.Kind)
+	}
+	if len(first.TraceID) != 32 || len(first.ID) != 16 || first.TraceID == second.TraceID {
+		t.Errorf("Unexpected IDs: %v/%v, %v/%v", first.TraceID, first.ID, second.TraceID, second.ID)
+	}
+	if *first.Duration < 15000 || *first.Duration > 20000 {
+		t.Errorf("Expected a duration up to `%v`, got `%v`", 20000, *first.Duration)
+	}
+	if first.Tags[tagRequestId] != "req-1" || first.Tags[dimQualifier] != "live" || first.Tags[dimFunctionName] != "helloworld" {
+		t.Errorf("Unexpected tags: %v", first.Tags)
+	}
+	if _, failed := first.Tags[tagError]; failed {
+		t.Errorf("Expected no error tag, got `%v`", first.Tags)
+	}
+	if second.Tags[tagError] != "true" || second.Tags[dimStatus] != "error" {
+		t.Errorf("Expected the error tag, got `%v`", second.Tags)
+	}
+}
+
+func TestSpanOfReportBeforeRuntimeDone(t *testing.T) {
+	t.Setenv("FAST_INGEST", "true")
+	t.Setenv("SPLUNK_TRACES_ENABLED", "true")
+
+	sink := &spanSink{}
+	emitter := NewWithSink(testConfiguration(), sink)
+	emitter.SetFunction("helloworld", "42")
+
+	emitter.Invoked(&extensionapi.Event{RequestId: "req-1", InvokedFunctionArn: testArn})
+
+	now := time.Now()
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(now, telemetry.PlatformReport, map[string]interface{}{
+			"requestId": "req-1",
+			"status":    "error",
+			"metrics":   map[string]interface{}{"durationMs": 20, "billedDurationMs": 20, "maxMemoryUsedMB": 64},
+		}),
+		platformEvent(now, telemetry.PlatformRuntimeDone, map[string]interface{}{
+			"requestId": "req-1",
+			"status":    "error",
+		}),
+	})
+
+	emitter.Invoked(&extensionapi.Event{RequestId: "req-2", InvokedFunctionArn: testArn})
+	if sc := emitter.Drain(false); sc != nil {
+		t.Fatalf("unexpected shutdown condition: %v", sc.Message())
+	}
+
+	if len(sink.spans) != 1 {
+		t.Fatalf("Expected `%v` spans, got `%v`", 1, len(sink.spans))
+	}
+	if span := sink.spans[0]; *span.Duration != 20000 || span.Tags[tagRequestId] != "req-1" || span.Tags[tagError] != "true" {
+		t.Errorf("Expected the span of the report, got `%v` and `%v`", *span.Duration, span.Tags)
+	}
+	if len(emitter.doneEarly) != 0 || len(emitter.reportedEarly) != 0 {
+		t.Errorf("Expected the runtime done of a reported request to be dropped, got `%v` and `%v`", emitter.doneEarly, emitter.reportedEarly)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/splunk/lambda-extension/internal/emf"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"log"
+	"time"
+)
+
+// reports of requests that never arrive (e.g. the subscription failed) must not accumulate forever
+const maxPendingRequests = 1000
+
+// pendingRequest links the telemetry of a request to the function ARN it was invoked with
+type pendingRequest struct {
+	functionArn string
+	invokedAt   time.Time
+	// the outcome and the span are taken from platform.runtimeDone, or from platform.report if the former was missing
+	outcomeCounted bool
+}
+
+func (emitter *MetricEmitter) HandleTelemetry(events []telemetry.Event) {
+	for _, event := range events {
+		switch event.Type {
+		case telemetry.PlatformRuntimeDone:
+			emitter.runtimeDone(event)
+		case telemetry.PlatformReport:
+			emitter.reported(event)
+		case telemetry.PlatformInitStart:
+			emitter.initStarted(event)
+		case telemetry.PlatformInitRuntimeDone, telemetry.PlatformInitReport:
+			emitter.initDone(event)
+		case telemetry.PlatformRestoreStart:
+			emitter.restoreStarted(event)
+		case telemetry.PlatformRestoreRuntimeDone:
+			emitter.restored(event)
+		case telemetry.Function:
+			emitter.functionLog(event)
+		}
+	}
+}
+
+func (emitter *MetricEmitter) runtimeDone(event telemetry.Event) {
+	record, err := event.PlatformRecord()
+	if err != nil {
+		log.Printf("unknown format of a %v record: %v\n", event.Type, err)
+		return
+	}
+
+	emitter.activity.runtimeDone(record.RequestId, event.Time)
+
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+
+	request, found := emitter.requests[record.RequestId]
+	if _, reported := emitter.reportedEarly[record.RequestId]; !found && reported {
+		// the report already took the outcome and the span
+		delete(emitter.reportedEarly, record.RequestId)
+		return
+	}
+	if !found {
+		// a short invocation can be reported before its INVOKE event is handled, Invoked picks it up then
+		log.Printf("runtime done of an unknown request: %v\n", record.RequestId)
+		if len(emitter.doneEarly) >= maxPendingRequests {
+			emitter.doneEarly = make(map[string]telemetry.Event)
+		}
+		emitter.doneEarly[record.RequestId] = event
+		return
+	}
+
+	emitter.arnToFunction[request.functionArn].outcomes.completed(record.Status)
+	request.outcomeCounted = true
+
+	if emitter.tracing {
+		emitter.recordSpan(record.RequestId, request, record.Status, event.Time)
+	}
+}
+
+func (emitter *MetricEmitter) reported(event telemetry.Event) {
+	record, err := event.PlatformRecord()
+	if err != nil {
+		log.Printf("unknown format of a %v record: %v\n", event.Type, err)
+		return
+	}
+
+	request, function := emitter.completeRequest(record.RequestId)
+	if function == nil {
+		log.Printf("report of an unknown request: %v\n", record.RequestId)
+		return
+	}
+
+	function.reports.reported(record.Metrics)
+
+	if !request.outcomeCounted {
+		function.outcomes.completed(record.Status)
+		if emitter.tracing {
+			emitter.mu.Lock()
+			emitter.recordSpan(record.RequestId, request, record.Status, reportedEnd(request, record, event.Time))
+			emitter.mu.Unlock()
+		}
+	}
+}
+
+// restoreStarted is reported when a SnapStart environment is restored from its snapshot,
+// the Extensions API doesn't deliver RESTORE events to the extensions, so it's only observed via the Telemetry API
+func (emitter *MetricEmitter) restoreStarted(event telemetry.Event) {
+	emitter.environmentMetrics.markRestore(event.Time)
+	emitter.activity.restored(event.Time)
+}
+
+func (emitter *MetricEmitter) restored(event telemetry.Event) {
+	record, err := event.PlatformRecord()
+	if err != nil {
+		log.Printf("unknown format of a %v record: %v\n", event.Type, err)
+		return
+	}
+
+	emitter.environmentMetrics.markRestored(event.Time, record.Status)
+}
+
+// functionLog looks for the Embedded Metric Format documents printed by the function code
+func (emitter *MetricEmitter) functionLog(event telemetry.Event) {
+	document, found := emf.FromLogRecord(event.Record)
+	if !found {
+		return
+	}
+
+	dps, err := emf.Parse(document)
+	if err != nil {
+		log.Printf("invalid EMF document: %v\n", err)
+		return
+	}
+
+	emitter.AddDatapoints(dps)
+}
+
+func (emitter *MetricEmitter) trackRequest(requestId, functionArn string, invokedAt time.Time) {
+	if len(emitter.requests) >= maxPendingRequests {
+		log.Printf("too many requests without a report, forgetting %v of them\n", len(emitter.requests))
+		emitter.requests = make(map[string]*pendingRequest)
+	}
+	emitter.requests[requestId] = &pendingRequest{functionArn: functionArn, invokedAt: invokedAt}
+}
+
+func (emitter *MetricEmitter) completeRequest(requestId string) (*pendingRequest, *functionMetrics) {
+	emitter.mu.Lock()
+	defer emitter.mu.Unlock()
+
+	request, found := emitter.requests[requestId]
+	if !found {
+		return nil, nil
+	}
+	delete(emitter.requests, requestId)
+
+	if !request.outcomeCounted {
+		// the runtime done may still follow, it must not be taken for one of a request yet to be invoked
+		if len(emitter.reportedEarly) >= maxPendingRequests {
+			emitter.reportedEarly = make(map[string]struct{})
+		}
+		emitter.reportedEarly[requestId] = struct{}{}
+	}
+
+	return request, emitter.arnToFunction[request.functionArn]
+}
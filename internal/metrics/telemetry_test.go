@@ -0,0 +1,230 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"encoding/json"
+	"github.com/signalfx/golib/v3/datapoint"
+	"github.com/splunk/lambda-extension/internal/config"
+	"github.com/splunk/lambda-extension/internal/extensionapi"
+	"github.com/splunk/lambda-extension/internal/telemetry"
+	"testing"
+	"time"
+)
+
+const testArn = "arn:aws:lambda:us-east-1:123456789012:function:helloworld:live"
+
+func TestReportedDurations(t *testing.T) {
+	emitter := New(testConfiguration())
+	emitter.SetFunction("helloworld", "42")
+
+	for _, requestId := range []string{"req-1", "req-2"} {
+		emitter.Invoked(&extensionapi.Event{RequestId: requestId, InvokedFunctionArn: testArn})
+	}
+
+	now := time.Now()
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(now, telemetry.PlatformReport, map[string]interface{}{
+			"requestId": "req-1",
+			"status":    "success",
+			"metrics":   map[string]interface{}{"durationMs": 12.5, "billedDurationMs": 13, "maxMemoryUsedMB": 64},
+		}),
+		platformEvent(now, telemetry.PlatformReport, map[string]interface{}{
+			"requestId": "req-2",
+			"status":    "success",
+			"metrics":   map[string]interface{}{"durationMs": 30, "billedDurationMs": 30, "maxMemoryUsedMB": 72},
+		}),
+	})
+
+	found := map[string]string{}
+	for _, dp := range emitter.scheduler.CollectDatapoints() {
+		if dp.Dimensions[dimQualifier] == "live" {
+			found[dp.Metric] = dp.Value.String()
+		}
+	}
+
+	expected := map[string]string{
+		functionDuration + ".count":     "2",
+		functionDuration + ".sum":       "42.5",
+		functionDuration + ".min":       "12.5",
+		functionDuration + ".max":       "30",
+		functionDuration + ".p50":       "12.5",
+		functionDuration + ".p99":       "30",
+		functionBilledDuration + ".sum": "43",
+		functionMaxMemoryUsed:           "72",
+	}
+	for metric, value := range expected {
+		if found[metric] != value {
+			t.Errorf("Expected `%v` of %v, got `%v`", value, metric, found[metric])
+		}
+	}
+}
+
+func TestOutcomesCountedOnce(t *testing.T) {
+	emitter := New(testConfiguration())
+	emitter.SetFunction("helloworld", "42")
+
+	for _, requestId := range []string{"req-1", "req-2", "req-3"} {
+		emitter.Invoked(&extensionapi.Event{RequestId: requestId, InvokedFunctionArn: testArn})
+	}
+
+	now := time.Now()
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(now, telemetry.PlatformRuntimeDone, map[string]interface{}{"requestId": "req-1", "status": "timeout"}),
+		platformEvent(now, telemetry.PlatformReport, map[string]interface{}{"requestId": "req-1", "status": "timeout"}),
+		platformEvent(now, telemetry.PlatformReport, map[string]interface{}{"requestId": "req-2", "status": "failure"}),
+		platformEvent(now, telemetry.PlatformRuntimeDone, map[string]interface{}{"requestId": "req-3", "status": "success"}),
+	})
+
+	found := map[string]string{}
+	for _, dp := range emitter.scheduler.CollectDatapoints() {
+		if dp.Dimensions[dimQualifier] == "live" {
+			found[dp.Metric] = dp.Value.String()
+		}
+	}
+
+	if found[functionErrors] != "1" || found[functionTimeouts] != "1" {
+		t.Errorf("Expected one error and one timeout, got `%v` and `%v`", found[functionErrors], found[functionTimeouts])
+	}
+}
+
+func TestRestore(t *testing.T) {
+	emitter := New(testConfiguration())
+	emitter.SetFunction("helloworld", "42")
+
+	restoreStart := time.Now().Add(-time.Second)
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(restoreStart, telemetry.PlatformRestoreStart, map[string]interface{}{}),
+		platformEvent(restoreStart.Add(250*time.Millisecond), telemetry.PlatformRestoreRuntimeDone, map[string]interface{}{"status": "success"}),
+	})
+
+	if !emitter.environmentMetrics.startTime.Equal(restoreStart) {
+		t.Errorf("Expected the start to be reset to `%v`, got `%v`", restoreStart, emitter.environmentMetrics.startTime)
+	}
+
+	found := map[string]string{}
+	for _, dp := range emitter.scheduler.CollectDatapoints() {
+		found[dp.Metric+":"+dp.Dimensions[dimStatus]] = dp.Value.String()
+	}
+
+	if found[functionRestore+":success"] != "1" || found[functionRestoreLatency+":"] != "250" {
+		t.Errorf("Expected a restore of 250ms, got `%v`", found)
+	}
+}
+
+func TestInitPhase(t *testing.T) {
+	emitter := New(testConfiguration())
+
+	initStart := time.Now()
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(initStart, telemetry.PlatformInitStart, map[string]interface{}{"initializationType": "provisioned-concurrency", "phase": "init"}),
+		platformEvent(initStart.Add(300*time.Millisecond), telemetry.PlatformInitRuntimeDone, map[string]interface{}{"phase": "init", "status": "error"}),
+		platformEvent(initStart.Add(310*time.Millisecond), telemetry.PlatformInitReport, map[string]interface{}{
+			"initializationType": "provisioned-concurrency",
+			"phase":              "init",
+			"status":             "error",
+			"metrics":            map[string]interface{}{"durationMs": 310},
+		}),
+	})
+
+	found := map[string]string{}
+	for _, dp := range emitter.scheduler.CollectDatapoints() {
+		if dp.Metric == functionInit || dp.Metric == functionInitDuration {
+			found[dp.Metric+":"+dp.Dimensions[dimInitType]+":"+dp.Dimensions[dimStatus]] = dp.Value.String()
+		}
+	}
+
+	expected := map[string]string{
+		functionInit + ":provisioned-concurrency:error":         "1",
+		functionInitDuration + ":provisioned-concurrency:error": "300",
+	}
+	if len(found) != len(expected) {
+		t.Errorf("Expected `%v`, got `%v`", expected, found)
+	}
+	for key, value := range expected {
+		if found[key] != value {
+			t.Errorf("Expected `%v` of %v, got `%v`", value, key, found[key])
+		}
+	}
+}
+
+func TestRestoreAfterTheFirstInvocation(t *testing.T) {
+	t.Setenv(initializationTypeEnv, snapStart)
+
+	emitter := New(testConfiguration())
+	emitter.SetFunction("helloworld", "42")
+	emitter.TelemetrySubscribed()
+
+	restoreStart := time.Now().Add(-300 * time.Millisecond)
+	emitter.Invoked(&extensionapi.Event{RequestId: "req-1", InvokedFunctionArn: testArn})
+
+	for _, dp := range emitter.environmentMetrics.adhocDps {
+		if dp.Metric == environmentStartDuration {
+			t.Fatalf("Expected the latency to wait for the restore, got `%v`", dp.Value)
+		}
+	}
+
+	emitter.HandleTelemetry([]telemetry.Event{
+		platformEvent(restoreStart, telemetry.PlatformRestoreStart, map[string]interface{}{}),
+		platformEvent(restoreStart.Add(250*time.Millisecond), telemetry.PlatformRestoreRuntimeDone, map[string]interface{}{"status": "success"}),
+	})
+
+	if current := emitter.activity.current; current == nil || current.requestId != "req-1" {
+		t.Errorf("Expected the period of `%v` to be in progress, got `%v`", "req-1", current)
+	}
+	active, frozen := emitter.activity.times(time.Now())
+	if active < 300*time.Millisecond || active > time.Second || frozen != 0 {
+		t.Errorf("Expected to be active since the restore, got `%v` active and `%v` frozen", active, frozen)
+	}
+
+	var latency int64 = -1
+	for _, dp := range emitter.scheduler.CollectDatapoints() {
+		if dp.Metric == environmentStartDuration {
+			latency = dp.Value.(datapoint.IntValue).Int()
+		}
+	}
+	if latency < 300 || latency > 1000 {
+		t.Errorf("Expected the latency to be measured from the restore, got `%v`", latency)
+	}
+}
+
+func TestSnapStartWithoutTelemetry(t *testing.T) {
+	t.Setenv(initializationTypeEnv, snapStart)
+
+	sink := &deadlineSink{}
+	emitter := NewWithSink(testConfiguration(), sink)
+	emitter.SetFunction("helloworld", "42")
+	emitter.Invoked(&extensionapi.Event{RequestId: "req-1", InvokedFunctionArn: testArn})
+	emitter.Drain(false)
+
+	found := false
+	for _, dp := range append(sink.points, emitter.scheduler.CollectDatapoints()...) {
+		found = found || dp.Metric == environmentStartDuration
+	}
+	if !found {
+		t.Errorf("Expected the latency not to wait for a restore which can't be reported")
+	}
+}
+
+// testConfiguration is read from the environment set by the test
+func testConfiguration() *config.Configuration {
+	configuration := config.New()
+	return &configuration
+}
+
+func platformEvent(timestamp time.Time, eventType string, record map[string]interface{}) telemetry.Event {
+	raw, _ := json.Marshal(record)
+	return telemetry.Event{Time: timestamp, Type: eventType, Record: raw}
+}
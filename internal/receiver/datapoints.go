@@ -0,0 +1,165 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const datapointPath = "/v2/datapoint"
+
+// Consumer receives the datapoints sent by the function code.
+type Consumer interface {
+	AddDatapoints(dps []*datapoint.Datapoint)
+}
+
+type jsonDatapoint struct {
+	Metric     string
+	Value      json.Number
+	Dimensions map[string]string
+	Timestamp  int64
+}
+
+// jsonDatapoints is the SignalFx /v2/datapoint JSON format
+type jsonDatapoints struct {
+	Gauge             []jsonDatapoint `json:"gauge"`
+	Counter           []jsonDatapoint `json:"counter"`
+	CumulativeCounter []jsonDatapoint `json:"cumulative_counter"`
+}
+
+type datapointHandler struct {
+	consumer Consumer
+}
+
+// ListenDatapoints starts an HTTP server accepting datapoints in the SignalFx JSON format,
+// so the function code can send custom metrics without any SignalFx client library.
+func ListenDatapoints(address string, consumer Consumer) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(datapointPath, &datapointHandler{consumer: consumer})
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Printf("datapoint receiver stopped: %v\n", err)
+		}
+	}()
+
+	log.Printf("datapoint receiver started on %v\n", listener.Addr())
+
+	return nil
+}
+
+func (handler *datapointHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "application/json") {
+		http.Error(w, "only application/json is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("can't decompress body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	}
+
+	dps, err := decodeDatapoints(body)
+	if err != nil {
+		log.Printf("rejected custom datapoints: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	handler.consumer.AddDatapoints(dps)
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`"OK"`))
+}
+
+func decodeDatapoints(body io.Reader) ([]*datapoint.Datapoint, error) {
+	var decoded jsonDatapoints
+
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("unknown format of datapoints: %v", err)
+	}
+
+	var dps []*datapoint.Datapoint
+	for _, typed := range []struct {
+		metricType datapoint.MetricType
+		dps        []jsonDatapoint
+	}{
+		{datapoint.Gauge, decoded.Gauge},
+		{datapoint.Count, decoded.Counter},
+		{datapoint.Counter, decoded.CumulativeCounter},
+	} {
+		for _, jdp := range typed.dps {
+			dp, err := jdp.toDatapoint(typed.metricType)
+			if err != nil {
+				return nil, err
+			}
+			dps = append(dps, dp)
+		}
+	}
+
+	return dps, nil
+}
+
+func (jdp jsonDatapoint) toDatapoint(metricType datapoint.MetricType) (*datapoint.Datapoint, error) {
+	if jdp.Metric == "" {
+		return nil, fmt.Errorf("metric name is missing")
+	}
+
+	var value datapoint.Value
+	if i, err := jdp.Value.Int64(); err == nil {
+		value = datapoint.NewIntValue(i)
+	} else if f, err := jdp.Value.Float64(); err == nil {
+		value = datapoint.NewFloatValue(f)
+	} else {
+		return nil, fmt.Errorf("invalid value of %v: %q", jdp.Metric, jdp.Value)
+	}
+
+	// a zero timestamp is replaced with the time of sending by the scheduler
+	var timestamp time.Time
+	if jdp.Timestamp > 0 {
+		timestamp = time.Unix(0, jdp.Timestamp*int64(time.Millisecond))
+	}
+
+	return datapoint.New(jdp.Metric, jdp.Dimensions, value, metricType, timestamp), nil
+}
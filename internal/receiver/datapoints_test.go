@@ -0,0 +1,76 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"github.com/signalfx/golib/v3/datapoint"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type collectingConsumer struct {
+	dps []*datapoint.Datapoint
+}
+
+func (cc *collectingConsumer) AddDatapoints(dps []*datapoint.Datapoint) {
+	cc.dps = append(cc.dps, dps...)
+}
+
+func TestDatapointHandler(t *testing.T) {
+	consumer := &collectingConsumer{}
+	handler := &datapointHandler{consumer: consumer}
+
+	body := `{
+		"gauge": [{"metric": "queue.size", "value": 3.5, "dimensions": {"queue": "orders"}}],
+		"counter": [{"metric": "orders.processed", "value": 7, "timestamp": 1600000000000}]
+	}`
+	req := httptest.NewRequest(http.MethodPost, datapointPath, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %v: %v", resp.Code, resp.Body.String())
+	}
+
+	if len(consumer.dps) != 2 {
+		t.Fatalf("Expected 2 datapoints, got %v", len(consumer.dps))
+	}
+
+	gauge, counter := consumer.dps[0], consumer.dps[1]
+	if gauge.MetricType != datapoint.Gauge || gauge.Value.String() != "3.5" || gauge.Dimensions["queue"] != "orders" {
+		t.Errorf("Unexpected gauge: %v", gauge)
+	}
+	if counter.MetricType != datapoint.Count || counter.Value.String() != "7" || counter.Timestamp.Unix() != 1600000000 {
+		t.Errorf("Unexpected counter: %v", counter)
+	}
+}
+
+func TestDatapointHandlerRejectsInvalidValue(t *testing.T) {
+	consumer := &collectingConsumer{}
+	handler := &datapointHandler{consumer: consumer}
+
+	req := httptest.NewRequest(http.MethodPost, datapointPath, strings.NewReader(`{"gauge": [{"metric": "x", "value": "abc"}]}`))
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest || len(consumer.dps) != 0 {
+		t.Errorf("Expected the request to be rejected, got %v", resp.Code)
+	}
+}
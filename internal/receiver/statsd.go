@@ -0,0 +1,290 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"fmt"
+	"github.com/signalfx/golib/v3/datapoint"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	statsdCounter      = "c"
+	statsdGauge        = "g"
+	statsdTimer        = "ms"
+	statsdHistogram    = "h"
+	statsdDistribution = "d"
+	statsdSet          = "s"
+)
+
+const maxPacketSize = 65535
+
+// bare DogStatsD tags (without a value) become dimensions with this value
+const bareTagValue = "true"
+
+// a gauge is forgotten after this many reports without an update
+const gaugeIdleReports = 10
+
+// lines of new series are dropped above this many series, so the tags can't grow the aggregator without limits
+const maxStatsdSeries = 10000
+
+type statsdSeries struct {
+	name       string
+	metricType string
+	dims       map[string]string
+
+	updated bool
+	// reports since the last update of a gauge
+	idleReports int
+	// the count is scaled by the sample rate, the mean is computed from the observed values only
+	count    float64
+	observed int64
+	sum      float64
+	min      float64
+	max      float64
+	last     float64
+	set      map[string]struct{}
+}
+
+// StatsdAggregator aggregates StatsD and DogStatsD lines between reports.
+// Counters are reported as deltas, timers and histograms as count, min, max and mean,
+// sets as the number of unique values and gauges as the last value.
+type StatsdAggregator struct {
+	mu     sync.Mutex
+	series map[string]*statsdSeries
+}
+
+func NewStatsdAggregator() *StatsdAggregator {
+	return &StatsdAggregator{series: make(map[string]*statsdSeries)}
+}
+
+// ListenStatsd starts a UDP listener feeding the aggregator.
+func ListenStatsd(address string, aggregator *StatsdAggregator) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				log.Printf("statsd receiver stopped: %v\n", err)
+				return
+			}
+			aggregator.AddPacket(string(buf[:n]))
+		}
+	}()
+
+	log.Printf("statsd receiver started on %v\n", conn.LocalAddr())
+
+	return nil
+}
+
+// AddPacket aggregates every line of a packet, the invalid ones are logged and skipped.
+func (sa *StatsdAggregator) AddPacket(packet string) {
+	for _, line := range strings.Split(packet, "\n") {
+		line = strings.TrimSpace(line)
+		// DogStatsD events and service checks can't be turned into datapoints
+		if line == "" || strings.HasPrefix(line, "_e{") || strings.HasPrefix(line, "_sc|") {
+			continue
+		}
+		if err := sa.addLine(line); err != nil {
+			log.Printf("invalid statsd line %q: %v\n", line, err)
+		}
+	}
+}
+
+// addLine parses <name>:<value>[:<value>...]|<type>[|@<sample rate>][|#<tag>,<tag>...]
+func (sa *StatsdAggregator) addLine(line string) error {
+	fields := strings.Split(line, "|")
+	if len(fields) < 2 {
+		return fmt.Errorf("metric type is missing")
+	}
+
+	nameAndValues := strings.Split(fields[0], ":")
+	if len(nameAndValues) < 2 || nameAndValues[0] == "" {
+		return fmt.Errorf("metric name or value is missing")
+	}
+
+	metricType := fields[1]
+	sampleRate := 1.0
+	dims := map[string]string{}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return fmt.Errorf("invalid sample rate: %v", field)
+			}
+			sampleRate = rate
+		case strings.HasPrefix(field, "#"):
+			for _, tag := range strings.Split(field[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				if kv := strings.SplitN(tag, ":", 2); len(kv) == 2 {
+					dims[kv[0]] = kv[1]
+				} else {
+					dims[tag] = bareTagValue
+				}
+			}
+		}
+	}
+
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	series := sa.seriesFor(nameAndValues[0], metricType, dims)
+	if series == nil {
+		return fmt.Errorf("too many series, at most %v are aggregated", maxStatsdSeries)
+	}
+
+	for _, value := range nameAndValues[1:] {
+		if err := series.add(value, sampleRate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seriesFor returns nil when a new series would be above maxStatsdSeries
+func (sa *StatsdAggregator) seriesFor(name, metricType string, dims map[string]string) *statsdSeries {
+	key := seriesKey(name, metricType, dims)
+
+	series, found := sa.series[key]
+	if !found {
+		if len(sa.series) >= maxStatsdSeries {
+			return nil
+		}
+		series = &statsdSeries{name: name, metricType: metricType, dims: dims}
+		sa.series[key] = series
+	}
+
+	return series
+}
+
+func seriesKey(name, metricType string, dims map[string]string) string {
+	keys := make([]string, 0, len(dims))
+	for k := range dims {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	builder := strings.Builder{}
+	builder.WriteString(name + "|" + metricType)
+	for _, k := range keys {
+		builder.WriteString("|" + k + "=" + dims[k])
+	}
+	return builder.String()
+}
+
+func (series *statsdSeries) add(value string, sampleRate float64) error {
+	if series.metricType == statsdSet {
+		if series.set == nil {
+			series.set = make(map[string]struct{})
+		}
+		series.set[value] = struct{}{}
+		series.updated = true
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value: %v", value)
+	}
+
+	switch series.metricType {
+	case statsdCounter:
+		series.sum += v / sampleRate
+	case statsdGauge:
+		// a signed value changes the previous gauge instead of replacing it
+		if strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-") {
+			series.last += v
+		} else {
+			series.last = v
+		}
+	case statsdTimer, statsdHistogram, statsdDistribution:
+		if series.observed == 0 || v < series.min {
+			series.min = v
+		}
+		if series.observed == 0 || v > series.max {
+			series.max = v
+		}
+		series.count += 1 / sampleRate
+		series.observed++
+		series.sum += v
+	default:
+		return fmt.Errorf("unknown metric type: %v", series.metricType)
+	}
+
+	series.updated = true
+	return nil
+}
+
+func (sa *StatsdAggregator) Datapoints() []*datapoint.Datapoint {
+	sa.mu.Lock()
+	defer sa.mu.Unlock()
+
+	var dps []*datapoint.Datapoint
+	for key, series := range sa.series {
+		if series.updated {
+			dps = append(dps, series.datapoints()...)
+		}
+
+		// gauges are kept, so the signed values can change them in the next interval, until they're idle for too long
+		if series.metricType != statsdGauge {
+			delete(sa.series, key)
+		} else if series.updated {
+			series.updated = false
+			series.idleReports = 0
+		} else if series.idleReports++; series.idleReports >= gaugeIdleReports {
+			delete(sa.series, key)
+		}
+	}
+
+	return dps
+}
+
+func (series *statsdSeries) datapoints() []*datapoint.Datapoint {
+	newDp := func(suffix string, value float64, metricType datapoint.MetricType) *datapoint.Datapoint {
+		return datapoint.New(series.name+suffix, series.dims, datapoint.NewFloatValue(value), metricType, time.Time{})
+	}
+
+	switch series.metricType {
+	case statsdCounter:
+		return []*datapoint.Datapoint{newDp("", series.sum, datapoint.Count)}
+	case statsdGauge:
+		return []*datapoint.Datapoint{newDp("", series.last, datapoint.Gauge)}
+	case statsdSet:
+		return []*datapoint.Datapoint{newDp("", float64(len(series.set)), datapoint.Gauge)}
+	default:
+		return []*datapoint.Datapoint{
+			newDp(".count", series.count, datapoint.Count),
+			newDp(".min", series.min, datapoint.Gauge),
+			newDp(".max", series.max, datapoint.Gauge),
+			newDp(".mean", series.sum/float64(series.observed), datapoint.Gauge),
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receiver
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestStatsdAggregation(t *testing.T) {
+	aggregator := NewStatsdAggregator()
+
+	aggregator.AddPacket("requests:1|c\nrequests:2|c|@0.5\n" +
+		"latency:10|ms|#handler:orders\nlatency:30|ms|#handler:orders\n" +
+		"queue:5|g\nqueue:-2|g\n" +
+		"users:alice|s\nusers:bob|s\nusers:alice|s\n" +
+		"sizes:1:2:3|h|#cached\n" +
+		"_e{5,4}:title|text\n" +
+		"broken\n")
+
+	found := map[string]string{}
+	for _, dp := range aggregator.Datapoints() {
+		found[dp.Metric] = dp.Value.String()
+		if dp.Metric == "latency.max" && dp.Dimensions["handler"] != "orders" {
+			t.Errorf("Expected the tag to become a dimension, got %v", dp.Dimensions)
+		}
+		if dp.Metric == "sizes.count" && dp.Dimensions["cached"] != bareTagValue {
+			t.Errorf("Expected the bare tag to become a dimension, got %v", dp.Dimensions)
+		}
+	}
+
+	expected := map[string]string{
+		"requests":      "5",
+		"latency.count": "2",
+		"latency.min":   "10",
+		"latency.max":   "30",
+		"latency.mean":  "20",
+		"queue":         "3",
+		"users":         "2",
+		"sizes.count":   "3",
+	}
+	for metric, value := range expected {
+		if found[metric] != value {
+			t.Errorf("Expected `%v` of %v, got `%v`", value, metric, found[metric])
+		}
+	}
+
+	aggregator.AddPacket("queue:+1|g")
+
+	dps := aggregator.Datapoints()
+	if len(dps) != 1 || dps[0].Value.String() != "4" {
+		t.Errorf("Expected only the changed gauge to be reported, got %v", dps)
+	}
+}
+
+func TestStatsdIdleGauges(t *testing.T) {
+	aggregator := NewStatsdAggregator()
+
+	aggregator.AddPacket("queue:5|g|#shard:1\nqueue:7|g|#shard:2")
+	aggregator.Datapoints()
+
+	for i := 0; i < gaugeIdleReports-1; i++ {
+		aggregator.AddPacket("queue:+1|g|#shard:2")
+		aggregator.Datapoints()
+	}
+
+	if len(aggregator.series) != 2 {
+		t.Fatalf("Expected `%v` series, got `%v`", 2, len(aggregator.series))
+	}
+
+	aggregator.Datapoints()
+
+	if _, found := aggregator.series[seriesKey("queue", statsdGauge, map[string]string{"shard": "1"})]; found {
+		t.Errorf("Expected the idle gauge to be forgotten")
+	}
+	if len(aggregator.series) != 1 {
+		t.Errorf("Expected `%v` series, got `%v`", 1, len(aggregator.series))
+	}
+}
+
+func TestStatsdSeriesLimit(t *testing.T) {
+	aggregator := NewStatsdAggregator()
+
+	for i := 0; i <= maxStatsdSeries; i++ {
+		if err := aggregator.addLine("requests:1|c|#id:" + strconv.Itoa(i)); err != nil && i < maxStatsdSeries {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if len(aggregator.series) != maxStatsdSeries {
+		t.Errorf("Expected `%v` series, got `%v`", maxStatsdSeries, len(aggregator.series))
+	}
+	if err := aggregator.addLine("requests:1|c|#id:0"); err != nil {
+		t.Errorf("Expected the existing series to be updated, got `%v`", err)
+	}
+}
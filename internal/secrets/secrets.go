@@ -0,0 +1,146 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets resolves the references to the values kept in AWS Secrets Manager or SSM Parameter Store.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the endpoint of both services can be overridden, e.g. to use a local stand-in
+const endpointEnv = "SPLUNK_SECRETS_ENDPOINT"
+
+const secretsManagerPrefix = "arn:aws:secretsmanager:"
+const ssmPrefix = "ssm:"
+
+const maxRetries = 2
+
+// fetchDeadline bounds a fetch including its retries, it runs before the extension registers,
+// so it must leave most of the init phase to the function (a variable, so the tests can shorten it)
+var fetchDeadline = 3 * time.Second
+
+var (
+	mu    sync.Mutex
+	cache = map[string]string{}
+)
+
+// IsReference tells whether the value refers to a secret instead of being the secret itself
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, secretsManagerPrefix) || strings.HasPrefix(value, ssmPrefix)
+}
+
+// Resolve returns the value of a Secrets Manager secret (referenced by its ARN) or an SSM parameter (referenced as ssm:/path),
+// any other value is returned as it is. The requests are signed with the credentials of the execution role found in the environment.
+// A fetched value is kept for the lifetime of the environment, a failed fetch is tried again on the next call.
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if resolved, found := cache[value]; found {
+		return resolved, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fetchDeadline)
+	defer cancel()
+
+	var resolved string
+	var err error
+	if strings.HasPrefix(value, secretsManagerPrefix) {
+		resolved, err = fetchSecret(ctx, value)
+	} else {
+		resolved, err = fetchParameter(ctx, strings.TrimPrefix(value, ssmPrefix))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cache[value] = resolved
+	return resolved, nil
+}
+
+func newSession(region string) (*session.Session, error) {
+	cfg := aws.NewConfig().
+		WithHTTPClient(&http.Client{Timeout: fetchDeadline}).
+		WithMaxRetries(maxRetries)
+
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	if endpoint := os.Getenv(endpointEnv); endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint)
+	}
+
+	return session.NewSession(cfg)
+}
+
+func fetchSecret(ctx context.Context, secretArn string) (string, error) {
+	parsedArn, err := arn.Parse(secretArn)
+	if err != nil {
+		return "", err
+	}
+
+	sess, err := newSession(parsedArn.Region)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := secretsmanager.New(sess).GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretArn),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if output.SecretString == nil {
+		return "", errors.New("the secret has no string value")
+	}
+	return *output.SecretString, nil
+}
+
+// fetchParameter uses the region of the function (AWS_REGION)
+func fetchParameter(ctx context.Context, name string) (string, error) {
+	sess, err := newSession("")
+	if err != nil {
+		return "", err
+	}
+
+	output, err := ssm.New(sess).GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", errors.New("the parameter has no value")
+	}
+	return *output.Parameter.Value, nil
+}
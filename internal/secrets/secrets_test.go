@@ -0,0 +1,144 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecretArn = "arn:aws:secretsmanager:us-east-1:123456789012:secret:splunk-token-AbCdEf"
+
+// standIn answers the GetSecretValue and GetParameter calls of both services
+func standIn(t *testing.T, requests *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"ARN": body["SecretId"], "SecretString": "secret-token-0123456789"})
+		case "AmazonSSM.GetParameter":
+			if body["Name"] != "/splunk/token" || body["WithDecryption"] != true {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"__type": "ParameterNotFound", "message": "not found"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Parameter": map[string]string{"Value": "ssm-token-0123456789"}})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv(endpointEnv, server.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	return server
+}
+
+func TestResolve(t *testing.T) {
+	var requests int32
+	standIn(t, &requests)
+
+	tests := []struct {
+		reference string
+		expected  string
+	}{
+		{"plain-token-0123456789", "plain-token-0123456789"},
+		{testSecretArn, "secret-token-0123456789"},
+		{"ssm:/splunk/token", "ssm-token-0123456789"},
+		{testSecretArn, "secret-token-0123456789"},
+	}
+
+	for _, test := range tests {
+		actual, err := Resolve(test.reference)
+		if err != nil {
+			t.Errorf("Unexpected error for %v: %v", test.reference, err)
+		}
+		if actual != test.expected {
+			t.Errorf("Expected `%v`, got `%v`", test.expected, actual)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("Expected the secrets to be fetched once, got `%v` requests", requests)
+	}
+}
+
+func TestResolveFailure(t *testing.T) {
+	var requests int32
+	standIn(t, &requests)
+
+	if _, err := Resolve("ssm:/missing"); err == nil {
+		t.Errorf("Expected an error")
+	}
+}
+
+func TestResolveFailureIsNotCached(t *testing.T) {
+	var requests int32
+	standIn(t, &requests)
+
+	for i := 0; i < 2; i++ {
+		if _, err := Resolve("ssm:/missing"); err == nil {
+			t.Errorf("Expected an error")
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("Expected the failed fetch to be tried again, got `%v` requests", requests)
+	}
+}
+
+func TestResolveDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	t.Setenv(endpointEnv, server.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	defer func(deadline time.Duration) { fetchDeadline = deadline }(fetchDeadline)
+	fetchDeadline = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := Resolve("ssm:/slow"); err == nil {
+		t.Errorf("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the fetch to give up after the deadline, took `%v`", elapsed)
+	}
+}
@@ -18,6 +18,7 @@ const (
 	internalError = "internal"
 	apiError      = "api"
 	metricError   = "metric"
+	configError   = "config"
 )
 
 type Condition interface {
@@ -60,6 +61,10 @@ func Metric(message string) Condition {
 	return newWithError(message, metricError)
 }
 
+func Config(message string) Condition {
+	return newWithError(message, configError)
+}
+
 func Reason(reason string) Condition {
 	return simple{reason: reason}
 }
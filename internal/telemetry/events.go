@@ -0,0 +1,72 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	PlatformReport             = "platform.report"
+	PlatformRuntimeDone        = "platform.runtimeDone"
+	PlatformInitStart          = "platform.initStart"
+	PlatformInitRuntimeDone    = "platform.initRuntimeDone"
+	PlatformInitReport         = "platform.initReport"
+	PlatformRestoreStart       = "platform.restoreStart"
+	PlatformRestoreRuntimeDone = "platform.restoreRuntimeDone"
+	Function                   = "function"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+	StatusTimeout = "timeout"
+	StatusFailure = "failure"
+)
+
+// Event is a single entry of a batch delivered by the Lambda Telemetry API.
+// The record's format depends on the event type, so it is decoded lazily.
+type Event struct {
+	Time   time.Time
+	Type   string
+	Record json.RawMessage
+}
+
+type ReportMetrics struct {
+	DurationMs       float64
+	BilledDurationMs float64
+	MemorySizeMB     int64
+	MaxMemoryUsedMB  int64
+	InitDurationMs   float64
+}
+
+// PlatformRecord covers the fields of platform.* records this extension is interested in.
+type PlatformRecord struct {
+	RequestId          string
+	Status             string
+	ErrorType          string
+	InitializationType string
+	Phase              string
+	Metrics            ReportMetrics
+}
+
+func (event Event) PlatformRecord() (*PlatformRecord, error) {
+	record := &PlatformRecord{}
+	if err := json.Unmarshal(event.Record, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,129 @@
+// Copyright Splunk Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type Handler interface {
+	HandleTelemetry(events []Event)
+}
+
+// Listener is the HTTP endpoint the Telemetry API pushes batches of events to.
+type Listener struct {
+	host     string
+	server   *http.Server
+	listener net.Listener
+	handler  Handler
+
+	mu           sync.Mutex
+	lastReceived time.Time
+}
+
+func Listen(address string, handler Handler) (*Listener, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	netListener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := &Listener{
+		host:     host,
+		listener: netListener,
+		handler:  handler,
+	}
+	listener.server = &http.Server{Handler: listener}
+
+	go func() {
+		if err := listener.server.Serve(netListener); err != nil && err != http.ErrServerClosed {
+			log.Printf("telemetry listener stopped: %v\n", err)
+		}
+	}()
+
+	log.Printf("telemetry listener started on %v\n", netListener.Addr())
+
+	return listener, nil
+}
+
+// URI returns the destination which should be passed to the subscription request.
+func (listener *Listener) URI() string {
+	_, port, _ := net.SplitHostPort(listener.listener.Addr().String())
+	return "http://" + net.JoinHostPort(listener.host, port)
+}
+
+func (listener *Listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("can't read telemetry batch: %v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var events []Event
+	if err := json.Unmarshal(body, &events); err != nil {
+		log.Printf("unknown format of a telemetry batch: %v\n", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	listener.mu.Lock()
+	listener.lastReceived = time.Now()
+	listener.mu.Unlock()
+
+	listener.handler.HandleTelemetry(events)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// WaitQuiet blocks until no batch has been received for the quiet period (or until max elapses).
+// Lambda delivers the outstanding telemetry after the environment is thawed for SHUTDOWN,
+// so this gives the last platform.report a chance to arrive before the final flush.
+func (listener *Listener) WaitQuiet(quiet, max time.Duration) {
+	start := time.Now()
+	deadline := start.Add(max)
+	for now := start; now.Before(deadline); now = time.Now() {
+		listener.mu.Lock()
+		last := listener.lastReceived
+		listener.mu.Unlock()
+
+		if last.Before(start) {
+			last = start
+		}
+
+		if now.Sub(last) >= quiet {
+			return
+		}
+		time.Sleep(quiet / 4)
+	}
+}
+
+func (listener *Listener) Close() {
+	if err := listener.server.Close(); err != nil {
+		log.Printf("can't close telemetry listener: %v\n", err)
+	}
+}